@@ -25,8 +25,9 @@ func ActionHandler(actions ...ActionSpec) AppRequestHandlerMapping {
 func getActionHandlerFunc(actions []ActionSpec) AppRequestHandler {
 	nameToSpec := map[string]ActionSpec{
 		// pre-defined actions
-		"logout":      logoutAction{},
-		"setLanguage": languageAction{},
+		"logout":         logoutAction{},
+		"setLanguage":    languageAction{},
+		"backupDatabase": backupAction{},
 	}
 	for _, a := range actions {
 		nameToSpec[a.Name()] = a
@@ -49,6 +50,10 @@ func getActionHandlerFunc(actions []ActionSpec) AppRequestHandler {
 			RespondNotFound(w)
 			return
 		}
+		addComponentLogFields(r, "action", actionName)
+
+		r, span := startSpan(r, "action "+actionName)
+		defer span.End()
 
 		// CSRF protection
 		if !IsCSRFtokenValid(r, r.Form.Get("csrf")) {
@@ -58,6 +63,17 @@ func getActionHandlerFunc(actions []ActionSpec) AppRequestHandler {
 		}
 
 		Log.InfoContextR(r, "execute action", LogContext{"name": actionName, "method": r.Method})
-		handleResponseAction(w, r, actionSpec.Do(w, r))
+		action, err := inRequestTransaction(
+			r, actionSpec,
+			func(r *http.Request) (*ResponseAction, error) {
+				return actionSpec.Do(w, r), nil
+			},
+		)
+		if err != nil {
+			Log.ErrorObjR(r, "could not execute action", err)
+			RespondInternalServerError(w)
+			return
+		}
+		handleResponseAction(w, r, action)
 	}
 }
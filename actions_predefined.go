@@ -1,7 +1,9 @@
 package uos
 
 import (
+	"fmt"
 	"net/http"
+	"path/filepath"
 )
 
 type logoutAction struct{}
@@ -11,6 +13,10 @@ func (a logoutAction) Name() string {
 }
 
 func (a logoutAction) Do(w http.ResponseWriter, r *http.Request) *ResponseAction {
+	if user, ok := r.Context().Value(ctxAppUser).(AppUser); ok && user.ID > 0 {
+		auditLog(r, AuditRecord{Action: "logout", Target: "user", TargetID: fmt.Sprint(user.ID)})
+	}
+
 	return ResponseClearSessionCookie()
 }
 
@@ -28,7 +34,7 @@ func (a languageAction) Do(w http.ResponseWriter, r *http.Request) *ResponseActi
 	user, ok := r.Context().Value(ctxAppUser).(AppUser)
 	if ok && user.Language != language {
 		// update language selection for current user
-		err := DB.Model(&user).Update("language", language).Error
+		err := DBContext(r).Model(&user).Update("language", language).Error
 		if err != nil {
 			Log.ErrorObjR(r, "could not update user language selection", err)
 		}
@@ -36,3 +42,36 @@ func (a languageAction) Do(w http.ResponseWriter, r *http.Request) *ResponseActi
 
 	return ResponseRefresh()
 }
+
+type backupAction struct{}
+
+// Backup action messages (message IDs, see form messages).
+const (
+	messageBackupFailed  = "backup failed"
+	messageBackupCreated = "backup created: %s"
+)
+
+func (a backupAction) Name() string {
+	return "backupDatabase"
+}
+
+// backups use a separate connection (not part of the request transaction)
+func (a backupAction) withoutTransaction() {}
+
+func (a backupAction) Do(w http.ResponseWriter, r *http.Request) *ResponseAction {
+	user, ok := r.Context().Value(ctxAppUser).(AppUser)
+	if !ok || !user.IsAdmin {
+		RespondForbidden(w)
+		return nil
+	}
+
+	file, err := BackupDatabase(r.Context())
+	if err != nil {
+		Log.ErrorObjR(r, "database backup failed", err)
+		return ResponseMessage(TR(r, messageBackupFailed), "danger")
+	}
+
+	auditLog(r, AuditRecord{Action: "backup", Target: "database", TargetID: filepath.Base(file)})
+
+	return ResponseMessage(TR(r, messageBackupCreated, filepath.Base(file)), "success")
+}
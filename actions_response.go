@@ -1,7 +1,10 @@
 package uos
 
 import (
+	"fmt"
 	"net/http"
+
+	"gorm.io/gorm"
 )
 
 // ResponseAction describes what happens on a successful save or delete form action.
@@ -16,6 +19,10 @@ type ResponseAction struct {
 	messageClass string
 
 	callback func(http.ResponseWriter)
+	audit    *AuditRecord
+
+	// ID of the saved entity
+	id string
 
 	redirect string
 }
@@ -46,6 +53,13 @@ func ResponseFormError(message string) *ResponseAction {
 	}
 }
 
+// WithID sets the ID of a created entity (audit log, JSON result). Form specifications should
+// set the ID when saving new entities.
+func (a *ResponseAction) WithID(id interface{}) *ResponseAction {
+	a.id = fmt.Sprint(id)
+	return a
+}
+
 // ResponseSetSessionCookie sets a session cookie for the specified user and
 // triggers a full frontend page refresh or a redirect to the given URL.
 func ResponseSetSessionCookie(userID uint, language string) *ResponseAction {
@@ -55,6 +69,12 @@ func ResponseSetSessionCookie(userID uint, language string) *ResponseAction {
 			setSession(userID, w)
 			setLanguage(language, w)
 		},
+		audit: &AuditRecord{
+			Action:   "login",
+			Target:   "user",
+			TargetID: fmt.Sprint(userID),
+			Actor:    &AppUser{Model: gorm.Model{ID: userID}},
+		},
 	}
 }
 
@@ -69,6 +89,14 @@ func ResponseClearSessionCookie() *ResponseAction {
 }
 
 func handleResponseAction(w http.ResponseWriter, r *http.Request, action *ResponseAction) {
+	if action == nil && isResponseWritten(w) {
+		// response already written (e.g. by the action handler)
+		return
+	}
+	if isJSONRequest(r) {
+		respondActionJSON(w, r, action)
+		return
+	}
 	if action == nil {
 		// return - do nothing
 		return
@@ -77,6 +105,9 @@ func handleResponseAction(w http.ResponseWriter, r *http.Request, action *Respon
 	if action.callback != nil {
 		action.callback(w)
 	}
+	if action.audit != nil {
+		auditLog(r, *action.audit)
+	}
 
 	if action.doPageRefresh {
 		if action.redirect != "" {
@@ -0,0 +1,271 @@
+package uos
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// AuditLogEntry represents a single recorded change in the audit log.
+type AuditLogEntry struct {
+	gorm.Model
+
+	// user who performed the action (0: anonymous)
+	ActorID   uint   `gorm:"index"`
+	ActorName string `gorm:"index;size:255"`
+
+	// action, e.g. "create", "update", "delete", "login", "logout"
+	Action string `gorm:"index;size:64"`
+	// changed entity, e.g. "form:customer"
+	Target string `gorm:"index;size:255"`
+	// ID(s) of the changed entity
+	TargetID string `gorm:"index;size:255"`
+
+	// JSON representation of values before/after the change
+	Before string
+	After  string
+
+	RequestID string
+	ClientIP  string
+}
+
+func (AuditLogEntry) TableName() string {
+	return "internal_audit_log"
+}
+
+// AuditRecord describes a change to be recorded in the audit log.
+type AuditRecord struct {
+	// action, e.g. "update"
+	Action string
+	// changed entity and ID
+	Target   string
+	TargetID string
+
+	// values before/after the change (optional, stored as JSON)
+	Before interface{}
+	After  interface{}
+
+	// acting user - determined from the request context if not specified
+	Actor *AppUser
+}
+
+// AuditLog records the specified change in the audit log. Actor, request ID and client IP are
+// taken from the request. Does nothing if the audit log is not enabled (see AuditConfiguration).
+func AuditLog(r *http.Request, record AuditRecord) error {
+	if !Config.Audit.Enabled {
+		return nil
+	}
+
+	entry := AuditLogEntry{
+		Action:   record.Action,
+		Target:   record.Target,
+		TargetID: record.TargetID,
+
+		ClientIP: clientIP(r),
+	}
+	if requestID, ok := r.Context().Value(ctxRequestID).(string); ok {
+		entry.RequestID = requestID
+	}
+
+	actor := record.Actor
+	if actor == nil {
+		if user, ok := r.Context().Value(ctxAppUser).(AppUser); ok {
+			actor = &user
+		}
+	}
+	if actor != nil && actor.ID > 0 && actor.Name == "" {
+		// only ID specified (e.g. on login) - load user
+		var user AppUser
+		if err := DBContext(r).First(&user, actor.ID).Error; err == nil {
+			actor = &user
+		}
+	}
+	if actor != nil {
+		entry.ActorID = actor.ID
+		entry.ActorName = actor.Name
+	}
+
+	var err error
+	entry.Before, err = auditValue(record.Before)
+	if err != nil {
+		return err
+	}
+	entry.After, err = auditValue(record.After)
+	if err != nil {
+		return err
+	}
+
+	Log.DebugContextR(
+		r, "audit log",
+		LogContext{"action": entry.Action, "target": entry.Target, "id": entry.TargetID},
+	)
+
+	return DBContext(r).Create(&entry).Error
+}
+
+func auditValue(value interface{}) (string, error) {
+	if value == nil {
+		return "", nil
+	}
+
+	data, err := json.Marshal(value)
+	if err != nil {
+		return "", err
+	}
+
+	return string(data), nil
+}
+
+// auditLog records the specified change. Errors are logged - the request is not affected.
+func auditLog(r *http.Request, record AuditRecord) {
+	err := AuditLog(r, record)
+	if err != nil {
+		Log.ErrorContextR(
+			r, "could not write audit log",
+			LogContext{"action": record.Action, "target": record.Target, "error": err},
+		)
+	}
+}
+
+// auditValues returns the form values for the audit log. Password values are masked.
+func (fi FormItems) auditValues() map[string]string {
+	values := map[string]string{}
+	for _, item := range fi {
+		if item.InputTypeHTML == "password" {
+			values[item.Name] = "***"
+			continue
+		}
+		values[item.Name] = item.Value
+
+		// group rows: "<group>.<row index>.<item>"
+		for i, row := range item.Rows {
+			for name, value := range row.auditValues() {
+				values[fmt.Sprintf("%s.%d.%s", item.Name, i, name)] = value
+			}
+		}
+	}
+
+	return values
+}
+
+// AuditTable returns a table specification showing the audit log. Only available for admin users.
+// Supports filtering by actor, action, target and target ID (URL parameters "f_actor_name",
+// "f_action", "f_target" and "f_target_id").
+func AuditTable() TableSpec {
+	return auditTable{}
+}
+
+type auditTable struct{}
+
+var auditTableFilterColumns = []string{"actor_name", "action", "target", "target_id"}
+
+func (t auditTable) Name() string         { return "audit" }
+func (t auditTable) ModelName() string    { return "AuditLogEntry" }
+func (t auditTable) ResourceName() string { return "" }
+
+func (t auditTable) query(c TableConfiguration) (*gorm.DB, error) {
+	user, ok := ContextUser(c.Context())
+	if !ok || !user.IsAdmin {
+		return nil, ErrorTableForbidden
+	}
+
+	query := DBFromContext(c.Context()).Model(&AuditLogEntry{})
+	for _, column := range auditTableFilterColumns {
+		if value, ok := c.Filter[column]; ok && value != "" {
+			query = query.Where(clause.Eq{Column: clause.Column{Name: column}, Value: value})
+		}
+	}
+
+	return query, nil
+}
+
+func (t auditTable) LoadData(c TableConfiguration) (TableData, error) {
+	query, err := t.query(c)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.SortColumn == "" {
+		// newest entries first
+		query = query.Order(clause.OrderByColumn{Column: clause.Column{Name: "created_at"}, Desc: true})
+	}
+
+	var entries []AuditLogEntry
+	err = c.ApplyQuery(query).Find(&entries).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return DBExtract(t.ModelName(), entries, c.Columns), nil
+}
+
+func (t auditTable) Count(c TableConfiguration) (int64, error) {
+	query, err := t.query(c)
+	if err != nil {
+		return 0, err
+	}
+
+	var count int64
+	return count, query.Count(&count).Error
+}
+
+func (t auditTable) ColumnInfo(columns []string) []TableColumn {
+	info := map[string]TableColumn{
+		"created_at": {
+			DisplayName: "Time",
+			IsSortable:  true,
+			Format: func(id, value interface{}) interface{} {
+				if t, ok := value.(time.Time); ok {
+					return t.Format("2006-01-02 15:04:05")
+				}
+				return value
+			},
+		},
+		"actor_name": {DisplayName: "User", IsSortable: true},
+		"action":     {DisplayName: "Action", IsSortable: true},
+		"target":     {DisplayName: "Target", IsSortable: true},
+		"target_id":  {DisplayName: "ID"},
+		"before":     {DisplayName: "Before"},
+		"after":      {DisplayName: "After"},
+		"request_id": {DisplayName: "Request"},
+		"client_ip":  {DisplayName: "Client"},
+	}
+
+	result := make([]TableColumn, len(columns))
+	for i, c := range columns {
+		if column, ok := info[c]; ok {
+			result[i] = column
+		} else {
+			result[i] = TableColumn{DisplayName: c}
+		}
+	}
+
+	return result
+}
+
+func (t auditTable) ColumnDefault() []string {
+	return []string{"created_at", "actor_name", "action", "target", "target_id", "before", "after"}
+}
+
+func (t auditTable) Actions() *TableActions {
+	return nil
+}
+
+func (t auditTable) DisplaySettings() TableDisplayProperties {
+	return TableDisplayProperties{
+		IsFullWidth:   true,
+		IsStriped:     true,
+		IsMobileReady: true,
+	}
+}
+
+// ContextUser returns the authenticated user of the given request context.
+func ContextUser(ctx context.Context) (AppUser, bool) {
+	user, ok := ctx.Value(ctxAppUser).(AppUser)
+	return user, ok
+}
@@ -17,22 +17,31 @@ type AppConfiguration struct {
 
 	// web application port
 	Port int `json:"port"`
+	// maximum time (seconds) to wait for active requests on shutdown (default: 10)
+	ShutdownTimeout int `json:"shutdown_timeout"`
+	// time (seconds) between reporting "not ready" and stopping the server on shutdown - allows
+	// load balancers to stop routing requests to this instance (default: 0)
+	ShutdownDelay int `json:"shutdown_delay"`
 	// base deployment directory - only files "below" this directory are used.
 	// If empty or not defined, the directory of the executable is set.
 	BaseDir string `json:"base_dir"`
 
 	Logging    LogConfiguration        `json:"logging"`
 	Monitoring MonitoringConfiguration `json:"monitoring"`
+	Tracing    TracingConfiguration    `json:"tracing"`
 	Database   DBConfiguration         `json:"database"`
 	Assets     AssetConfiguration      `json:"assets"`
 	I18N       I18NConfiguration       `json:"i18n"`
 
-	Auth AuthenticationConfiguration `json:"auth"`
+	Auth  AuthenticationConfiguration `json:"auth"`
+	Audit AuditConfiguration          `json:"audit"`
 
 	// page configuration integrated into HTML pages. To define common settings
 	// the page "_default" can be specified.
 	Pages map[string]PageConfiguration `json:"pages"`
 
+	Files FileConfiguration `json:"files"`
+
 	Tuning   TuningConfiguration  `json:"tuning"`
 	Features FeatureConfiguration `json:"-"`
 }
@@ -43,6 +52,14 @@ type LogConfiguration struct {
 	Level string `json:"level"`
 	// write logmessages as colored output to stderr - otherwise log as JSON
 	UseConsole bool `json:"use_console"`
+	// log outputs (replaces the default output selected by UseConsole)
+	Sinks []LogSinkConfiguration `json:"sinks"`
+	// sampling and deduplication of frequent messages
+	Sampling LogSamplingConfiguration `json:"sampling"`
+	// IP addresses or networks (CIDR) of reverse proxies - the client IP (logging, audit log)
+	// and the request ID are only taken from the "X-Forwarded-For" and "X-Request-ID" headers
+	// of requests sent by these proxies
+	TrustedProxies []string `json:"trusted_proxies"`
 }
 
 // MonitoringConfiguration specifies ports for application monitoring.
@@ -51,12 +68,84 @@ type MonitoringConfiguration struct {
 	PortPPROF int `json:"pprof"`
 	// port for application metrics (Prometheus)
 	PortMetrics int `json:"metrics"`
+	// bearer token for admin endpoints on the metrics port - admin endpoints are disabled if empty
+	AdminToken string `json:"admin_token"`
+}
+
+// TracingConfiguration specifies OpenTelemetry tracing.
+type TracingConfiguration struct {
+	// activate tracing (spans for requests, components, templates and DB queries)
+	Enabled bool `json:"enabled"`
+	// span exporter: "stdout" (default) or "none" (application registers exporters using
+	// RegisterTraceExporter, e.g. an OTLP exporter)
+	Exporter string `json:"exporter"`
+	// service name (default: executable name)
+	ServiceName string `json:"service_name"`
+	// fraction of traced requests (0 < ratio <= 1, default: 1)
+	SampleRatio float64 `json:"sample_ratio"`
 }
 
 // DBConfiguration specifies the database.
 type DBConfiguration struct {
+	// database driver: "sqlite" (default), "postgres" or "mysql"
+	Driver string `json:"driver"`
 	// SQLite database file
 	File string `json:"file"`
+	// data source name (postgres, mysql) - can reference environment variables, e.g. ${DB_PASSWORD}
+	DSN string `json:"dsn"`
+
+	// connection pool settings (0/empty: driver defaults)
+	MaxOpenConns    int    `json:"max_open_conns"`
+	MaxIdleConns    int    `json:"max_idle_conns"`
+	ConnMaxLifetime string `json:"conn_max_lifetime"`
+	ConnMaxIdleTime string `json:"conn_max_idle_time"`
+
+	// SQLite tuning (empty/0: SQLite defaults)
+	// journal mode, e.g. "WAL" to allow concurrent readers during writes
+	JournalMode string `json:"journal_mode"`
+	// time in milliseconds to wait for a locked database before failing with "database is locked"
+	BusyTimeout int `json:"busy_timeout"`
+	// synchronous level: "OFF", "NORMAL", "FULL" or "EXTRA"
+	Synchronous string `json:"synchronous"`
+	// online backups of the SQLite database
+	Backup DBBackupConfiguration `json:"backup"`
+
+	// directory containing SQL migration files ("<version>_<description>.sql"), relative to the
+	// base directory
+	Migrations string `json:"migrations"`
+	// do not execute pending migrations during ComponentSetup
+	SkipMigrations bool `json:"skip_migrations"`
+
+	// execute form saves/deletes, table deletes and actions inside a database transaction
+	// (available through DBContext/DBFromContext) - committed on success, rolled back on error.
+	// Specs without context parameter (FormSpecSave, FormSpecDelete, TableSpecDelete) are not
+	// executed inside a transaction.
+	RequestTransactions bool `json:"request_transactions"`
+}
+
+// DBBackupConfiguration specifies online backups of a SQLite database.
+type DBBackupConfiguration struct {
+	// directory for backup files (relative to the base directory) - backups are disabled if empty
+	Dir string `json:"dir"`
+	// interval of scheduled backups (e.g. "6h") - only manual backups if empty
+	Interval string `json:"interval"`
+	// number of backup files to keep (0: keep all)
+	Retain int `json:"retain"`
+}
+
+// FileConfiguration specifies the storage of uploaded files.
+type FileConfiguration struct {
+	// directory for stored files (relative paths: below the base directory), default: "files"
+	Dir string `json:"dir"`
+	// maximum size of (multipart) upload requests in MB, default: 32
+	MaxRequestSize int64 `json:"max_request_size_mb"`
+}
+
+func (c FileConfiguration) maxRequestSize() int64 {
+	if c.MaxRequestSize <= 0 {
+		return 32 << 20
+	}
+	return c.MaxRequestSize << 20
 }
 
 // AssetConfiguration specifies directories containing different types of static data.
@@ -86,6 +175,12 @@ type AuthenticationConfiguration struct {
 	block []byte
 }
 
+// AuditConfiguration specifies the audit log.
+type AuditConfiguration struct {
+	// record changes (form save/delete, table delete, login/logout) in the audit log
+	Enabled bool `json:"enabled"`
+}
+
 type PageConfiguration struct {
 	Title       string `json:"title"`
 	Description string `json:"description"`
@@ -175,6 +270,14 @@ func readConfiguration(configFilePath string) error {
 	return nil
 }
 
+// basePath returns the path relative to the base directory (absolute paths are unchanged).
+func basePath(path string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(Config.BaseDir, path)
+}
+
 func (c AppConfiguration) getPageConfig(pageName string) PageConfiguration {
 	var (
 		result     = c.Pages["_default"].clone()
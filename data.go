@@ -1,11 +1,19 @@
 package uos
 
 import (
+	"context"
 	"fmt"
+	"net/http"
+	"net/url"
+	"os"
 	"reflect"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/glebarez/sqlite"
+	"gorm.io/driver/mysql"
+	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 	"gorm.io/gorm/logger"
 	"gorm.io/gorm/schema"
@@ -15,10 +23,23 @@ import (
 var DB *gorm.DB
 
 func setupDataAccess() {
-	Log.InfoContext("open SQLite database file", LogContext{"file": Config.Database.File})
+	openDataAccess()
+	runMigrationsOnSetup()
+
+	setupDatabaseBackups()
+	setupFormDrafts()
+}
+
+// openDataAccess opens the database, registers the framework models and migrations (without
+// executing migrations or starting background tasks).
+func openDataAccess() {
+	dialector, err := newDialector(Config.Database)
+	if err != nil {
+		Log.PanicError("invalid database configuration", err)
+	}
 
 	dbAccess, err := gorm.Open(
-		sqlite.Open(Config.Database.File),
+		dialector,
 		&gorm.Config{
 			Logger: logger.Default.LogMode(logger.Silent),
 		},
@@ -28,14 +49,133 @@ func setupDataAccess() {
 	}
 	DB = dbAccess
 
+	err = configureConnectionPool(DB, Config.Database)
+	if err != nil {
+		Log.PanicError("could not configure database connection pool", err)
+	}
+
+	registerDBTracing(DB)
+	registerDBVersioning(DB)
+
+	// empty database: current schema is created by auto-migration (see setupMigrations)
+	tables, err := DB.Migrator().GetTables()
+	if err != nil {
+		Log.PanicError("could not read database tables", err)
+	}
+
 	Log.Info("register framework models")
 	RegisterDBModels(
 		AppUser{},
+		AuditLogEntry{},
+		StoredFile{},
+		FormDraft{},
 	)
+
+	setupMigrations(len(tables) == 0)
+}
+
+func newDialector(c DBConfiguration) (gorm.Dialector, error) {
+	// DSN can reference environment variables, e.g. "password=${DB_PASSWORD}"
+	dsn := os.ExpandEnv(c.DSN)
+
+	switch c.Driver {
+	case "", "sqlite":
+		file := c.File
+		if file == "" {
+			file = dsn
+		}
+		Log.InfoContext("open SQLite database file", LogContext{"file": file})
+		file, err := sqliteDSN(file, c)
+		if err != nil {
+			return nil, err
+		}
+		return sqlite.Open(file), nil
+	case "postgres":
+		Log.Info("open PostgreSQL database")
+		return postgres.Open(dsn), nil
+	case "mysql":
+		Log.Info("open MySQL database")
+		return mysql.Open(dsn), nil
+	}
+
+	return nil, fmt.Errorf("unknown database driver: '%s'", c.Driver)
+}
+
+// sqliteDSN appends the configured tuning parameters to the SQLite database file.
+// The pragmas are executed by the driver for every new connection of the pool.
+func sqliteDSN(file string, c DBConfiguration) (string, error) {
+	pragmas := []string{}
+
+	if c.JournalMode != "" {
+		mode := strings.ToUpper(c.JournalMode)
+		if !contains([]string{"DELETE", "TRUNCATE", "PERSIST", "MEMORY", "WAL", "OFF"}, mode) {
+			return "", fmt.Errorf("invalid SQLite journal mode: '%s'", c.JournalMode)
+		}
+		pragmas = append(pragmas, fmt.Sprintf("journal_mode(%s)", mode))
+	}
+	if c.BusyTimeout > 0 {
+		pragmas = append(pragmas, fmt.Sprintf("busy_timeout(%d)", c.BusyTimeout))
+	}
+	if c.Synchronous != "" {
+		level := strings.ToUpper(c.Synchronous)
+		if !contains([]string{"OFF", "NORMAL", "FULL", "EXTRA"}, level) {
+			return "", fmt.Errorf("invalid SQLite synchronous level: '%s'", c.Synchronous)
+		}
+		pragmas = append(pragmas, fmt.Sprintf("synchronous(%s)", level))
+	}
+
+	if len(pragmas) == 0 {
+		return file, nil
+	}
+
+	params := url.Values{"_pragma": pragmas}
+	separator := "?"
+	if strings.Contains(file, "?") {
+		separator = "&"
+	}
+	return file + separator + params.Encode(), nil
+}
+
+func configureConnectionPool(db *gorm.DB, c DBConfiguration) error {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+
+	if c.MaxOpenConns > 0 {
+		sqlDB.SetMaxOpenConns(c.MaxOpenConns)
+	}
+	if c.MaxIdleConns > 0 {
+		sqlDB.SetMaxIdleConns(c.MaxIdleConns)
+	}
+	if c.ConnMaxLifetime != "" {
+		d, err := time.ParseDuration(c.ConnMaxLifetime)
+		if err != nil {
+			return err
+		}
+		sqlDB.SetConnMaxLifetime(d)
+	}
+	if c.ConnMaxIdleTime != "" {
+		d, err := time.ParseDuration(c.ConnMaxIdleTime)
+		if err != nil {
+			return err
+		}
+		sqlDB.SetConnMaxIdleTime(d)
+	}
+
+	return nil
+}
+
+// DBContext returns the database connection using the context of the given request.
+// Queries are traced as part of the request (if tracing is enabled). Inside a request
+// transaction the transaction is returned.
+func DBContext(r *http.Request) *gorm.DB {
+	return DBFromContext(r.Context())
 }
 
 func cleanupDataAccess() {
-	// do nothing
+	cleanupFormDrafts()
+	cleanupDatabaseBackups()
 }
 
 // RegisterDBModels executes Gorm auto-migration for all specified models.
@@ -55,10 +195,41 @@ func RegisterDBModels(models ...interface{}) {
 }
 
 type dbModelInfo struct {
+	// pointer to an empty model instance (used for queries)
+	model interface{}
 	table string
 
 	columns   []string
 	columnMap map[string]string
+
+	// column used for optimistic concurrency control (field tagged with `uos:"version"`)
+	versionColumn string
+
+	// application fields (without primary key, timestamps and version) in declaration order
+	fields []dbModelField
+}
+
+// dbModelField describes an application field of a model including its "uos" tag settings.
+type dbModelField struct {
+	name   string
+	column string
+	typ    reflect.Type
+
+	settings map[string]string
+}
+
+// label returns the display name of the field (setting "label", default: field name).
+func (f dbModelField) label() string {
+	if label := f.settings["label"]; label != "" {
+		return label
+	}
+	return f.name
+}
+
+// has checks whether the specified flag is set, e.g. "required".
+func (f dbModelField) has(flag string) bool {
+	_, ok := f.settings[flag]
+	return ok
 }
 
 var dbModelInfos = map[string]dbModelInfo{}
@@ -70,6 +241,7 @@ func analyzeModel(model interface{}) {
 	}
 
 	info := dbModelInfo{
+		model:     reflect.New(s.ModelType).Interface(),
 		table:     s.Table,
 		columns:   []string{},
 		columnMap: map[string]string{},
@@ -78,23 +250,81 @@ func analyzeModel(model interface{}) {
 	for _, field := range s.Fields {
 		info.columns = append(info.columns, field.DBName)
 		info.columnMap[field.DBName] = field.Name
+
+		settings := parseTagSettings(field.Tag.Get("uos"))
+		if _, ok := settings["version"]; ok {
+			switch field.FieldType.Kind() {
+			case reflect.Int, reflect.Int32, reflect.Int64, reflect.Uint, reflect.Uint32, reflect.Uint64:
+				info.versionColumn = field.DBName
+			default:
+				Log.PanicContext("version field must be an integer", LogContext{"model": s.Name, "field": field.Name})
+			}
+			continue
+		}
+
+		if expr := settings["regexp"]; expr != "" {
+			_, err := compileFormRegexp(expr)
+			if err != nil {
+				Log.PanicContext(
+					"invalid form item regexp",
+					LogContext{"model": s.Name, "field": field.Name, "regexp": expr, "error": err},
+				)
+			}
+		}
+
+		// application fields: skip primary key, timestamps, soft-delete marker and excluded fields
+		_, isExcluded := settings["-"]
+		if field.DBName == "" || field.PrimaryKey || isExcluded ||
+			field.AutoCreateTime != 0 || field.AutoUpdateTime != 0 ||
+			field.FieldType == reflect.TypeOf(gorm.DeletedAt{}) {
+			continue
+		}
+		info.fields = append(info.fields, dbModelField{
+			name:     field.Name,
+			column:   field.DBName,
+			typ:      field.FieldType,
+			settings: settings,
+		})
 	}
 
 	Log.DebugContext("analyzed DB model", LogContext{"name": s.Name, "columns": info.columns})
 	dbModelInfos[s.Name] = info
 }
 
+// parseTagSettings parses a "uos" struct tag, e.g. `uos:"label=Name,required"`.
+// Returns a map of setting names to values ("" for flags).
+func parseTagSettings(tag string) map[string]string {
+	settings := map[string]string{}
+	for _, s := range strings.Split(tag, ",") {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+
+		key, value, _ := strings.Cut(s, "=")
+		settings[strings.TrimSpace(key)] = strings.TrimSpace(value)
+	}
+
+	return settings
+}
+
 func dbColumns(name string) []string {
 	return dbModelInfos[name].columns
 }
 
-func dbEntryCount(name string) (int64, error) {
+func dbEntryCount(ctx context.Context, name string) (int64, error) {
 	if name == "" {
 		return -1, nil
 	}
 
+	info, ok := dbModelInfos[name]
+	if !ok {
+		return 0, fmt.Errorf("model '%s' not registered", name)
+	}
+
+	// soft-deleted entries are excluded by Gorm (if the model supports soft-delete)
 	var count int64
-	return count, DB.Table(dbModelInfos[name].table).Where("deleted_at IS NULL").Count(&count).Error
+	return count, DBFromContext(ctx).Model(info.model).Count(&count).Error
 }
 
 // DBExtract returns a table of the specified columns extracted from the given list of models.
@@ -126,7 +356,9 @@ func DBExtract(name string, models interface{}, columns []string) TableData {
 }
 
 // DBExtractForm fills form items with values based on the specified model.
-// The model must be registered using Register DBModels.
+// The model must be registered using Register DBModels. For models with a version field a
+// hidden version item is added (used to detect concurrent changes on save). Password items are
+// not filled (stored passwords or hashes are never sent to the client).
 // Panics if "name" specifies an unknown model.
 func DBExtractForm(name string, model interface{}, items FormItems) FormItems {
 	info, ok := dbModelInfos[name]
@@ -135,10 +367,37 @@ func DBExtractForm(name string, model interface{}, items FormItems) FormItems {
 	}
 
 	for pos, i := range items {
-		items[pos].Value = fmt.Sprintf(
+		if i.Name == FormVersionItem || i.InputTypeHTML == "password" {
+			continue
+		}
+		items[pos].Value = formatFormValue(
+			reflect.ValueOf(model).FieldByName(info.columnMap[i.Name]),
+			i.InputTypeHTML,
+		)
+	}
+
+	if info.versionColumn != "" {
+		version := fmt.Sprintf(
 			"%v",
-			reflect.ValueOf(model).FieldByName(info.columnMap[i.Name]).Interface(),
+			reflect.ValueOf(model).FieldByName(info.columnMap[info.versionColumn]).Interface(),
 		)
+
+		found := false
+		for pos, i := range items {
+			if i.Name == FormVersionItem {
+				items[pos].Value = version
+				found = true
+			}
+		}
+		if !found {
+			items = append(items, FormItem{
+				InputType:     "input",
+				InputTypeHTML: "text",
+				Name:          FormVersionItem,
+				Value:         version,
+				IsHidden:      true,
+			})
+		}
 	}
 
 	return items
@@ -0,0 +1,147 @@
+package uos
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	backupFilePrefix = "backup-"
+	backupFileSuffix = ".db"
+)
+
+var dbBackup = struct {
+	// serializes backups (scheduled and manual)
+	sync.Mutex
+
+	stop chan struct{}
+}{}
+
+func setupDatabaseBackups() {
+	c := Config.Database.Backup
+	if c.Dir == "" || c.Interval == "" {
+		return
+	}
+	if !isSQLite() {
+		Log.Warn("scheduled backups are only supported for SQLite databases")
+		return
+	}
+
+	interval, err := time.ParseDuration(c.Interval)
+	if err != nil || interval <= 0 {
+		Log.PanicContext("invalid database backup interval", LogContext{"interval": c.Interval})
+	}
+
+	Log.InfoContext("schedule database backups", LogContext{"dir": basePath(c.Dir), "interval": interval.String()})
+
+	dbBackup.stop = make(chan struct{})
+	go runScheduledBackups(interval, dbBackup.stop)
+}
+
+func cleanupDatabaseBackups() {
+	if dbBackup.stop != nil {
+		close(dbBackup.stop)
+		dbBackup.stop = nil
+	}
+}
+
+func runScheduledBackups(interval time.Duration, stop chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			_, err := BackupDatabase(context.Background())
+			if err != nil {
+				Log.ErrorObj("scheduled database backup failed", err)
+			}
+		case <-stop:
+			return
+		}
+	}
+}
+
+func isSQLite() bool {
+	return Config.Database.Driver == "" || Config.Database.Driver == "sqlite"
+}
+
+// BackupDatabase writes a consistent copy of the SQLite database to the configured backup
+// directory (using "VACUUM INTO" - the database stays online) and removes old backups
+// according to the retention setting. Returns the path of the backup file. Must not be called
+// inside a request transaction (requires a separate connection).
+func BackupDatabase(ctx context.Context) (string, error) {
+	c := Config.Database.Backup
+	if c.Dir == "" {
+		return "", fmt.Errorf("no backup directory configured")
+	}
+	if !isSQLite() {
+		return "", fmt.Errorf("backups are only supported for SQLite databases")
+	}
+
+	dbBackup.Lock()
+	defer dbBackup.Unlock()
+
+	dir := basePath(c.Dir)
+	err := os.MkdirAll(dir, 0o750)
+	if err != nil {
+		return "", err
+	}
+
+	file := filepath.Join(
+		dir,
+		backupFilePrefix+time.Now().UTC().Format("20060102T150405.000000")+backupFileSuffix,
+	)
+
+	start := time.Now()
+	err = DB.WithContext(ctx).Exec(
+		fmt.Sprintf("VACUUM INTO '%s'", strings.ReplaceAll(file, "'", "''")),
+	).Error
+	if err != nil {
+		return "", err
+	}
+	Log.InfoContext(
+		"database backup created",
+		LogContext{"file": file, "duration": time.Since(start).String()},
+	)
+
+	err = pruneDatabaseBackups(dir, c.Retain)
+	if err != nil {
+		Log.ErrorObj("could not remove old database backups", err)
+	}
+
+	return file, nil
+}
+
+// pruneDatabaseBackups removes all but the newest "retain" backup files.
+func pruneDatabaseBackups(dir string, retain int) error {
+	if retain <= 0 {
+		return nil
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, backupFilePrefix+"*"+backupFileSuffix))
+	if err != nil {
+		return err
+	}
+	if len(files) <= retain {
+		return nil
+	}
+
+	// file names contain the timestamp - lexical order is chronological order
+	sort.Strings(files)
+	for _, f := range files[:len(files)-retain] {
+		err = os.Remove(f)
+		if err != nil {
+			return err
+		}
+		Log.DebugContext("removed database backup", LogContext{"file": f})
+	}
+
+	return nil
+}
@@ -0,0 +1,424 @@
+package uos
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strconv"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Model specifications are generic table and form implementations for registered models.
+// Columns and form items are derived from the application fields of the model (all fields
+// except primary key, timestamps, soft-delete marker and version). Field properties are
+// configured using "uos" struct tags, e.g. `uos:"label=Name,required,sortable"`:
+//
+//	label=<text>        display name (table header and form label), default: field name
+//	required            form value is mandatory
+//	sortable            table can be sorted by this column
+//	notable / noform    field is not shown in tables / forms
+//	type=<input type>   input type, e.g. "password", "textarea" or "radio"
+//	options=<a|b|c>     valid values - rendered as select
+//	placeholder=<text>  form placeholder
+//	default=<value>     default value for new entries
+//	min=<n>, max=<n>    number range
+//	minlen=<n>, maxlen=<n>  text length
+//	regexp=<expr>       regular expression the value must match (without commas)
+//	-                   field is ignored
+
+// ModelTable is a generic table specification for the registered model T.
+// All hooks are optional.
+type ModelTable[T any] struct {
+	// table name (available at '/tables/<name>')
+	TableName string
+	// resource name of expandable detail rows (see TableDisplayProperties.IsExpandable)
+	Resource string
+	// default columns - default: all table columns of the model
+	Columns []string
+
+	// table actions
+	TableActions *TableActions
+	// display settings
+	Display TableDisplayProperties
+	// formatting functions by column
+	Format map[string]TableFormatFunc
+
+	// Query can restrict or extend the table query, e.g. for access checks.
+	// The query already contains the column filters (URL parameters "f_<column>").
+	// Deletes are restricted to entries returned by the query.
+	Query func(c TableConfiguration, query *gorm.DB) (*gorm.DB, error)
+	// BeforeDelete is called before the specified (accessible) entries are deleted.
+	BeforeDelete func(ctx context.Context, ids []uint) error
+}
+
+func (t ModelTable[T]) Name() string         { return t.TableName }
+func (t ModelTable[T]) ModelName() string    { return modelName[T]() }
+func (t ModelTable[T]) ResourceName() string { return t.Resource }
+
+func (t ModelTable[T]) query(c TableConfiguration) (*gorm.DB, error) {
+	query := DBFromContext(c.Context()).Model(new(T))
+	for _, f := range modelInfo[T]().fields {
+		if f.has("notable") && !contains(t.Columns, f.column) {
+			// hidden fields can not be filtered
+			continue
+		}
+		if value, ok := c.Filter[f.column]; ok && value != "" {
+			query = query.Where(clause.Eq{Column: clause.Column{Name: f.column}, Value: value})
+		}
+	}
+
+	if t.Query != nil {
+		return t.Query(c, query)
+	}
+	return query, nil
+}
+
+func (t ModelTable[T]) LoadData(c TableConfiguration) (TableData, error) {
+	query, err := t.query(c)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []T
+	err = c.ApplyQuery(query).Find(&entries).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return DBExtract(t.ModelName(), entries, c.Columns), nil
+}
+
+func (t ModelTable[T]) Count(c TableConfiguration) (int64, error) {
+	query, err := t.query(c)
+	if err != nil {
+		return 0, err
+	}
+
+	var count int64
+	return count, query.Count(&count).Error
+}
+
+// isValidConfiguration checks that the (client-specified) columns, filters and sort column
+// only reference table columns - hidden fields ("notable") must not be accessible. The sort
+// column must be sortable.
+func (t ModelTable[T]) isValidConfiguration(c TableConfiguration) bool {
+	visible := t.ColumnDefault()
+	sortable := []string{}
+	for _, f := range modelInfo[T]().fields {
+		if f.has("notable") && !contains(t.Columns, f.column) {
+			continue
+		}
+		visible = append(visible, f.column)
+		if f.has("sortable") {
+			sortable = append(sortable, f.column)
+		}
+	}
+
+	for _, column := range c.Columns {
+		if !contains(visible, column) {
+			return false
+		}
+	}
+	for column := range c.Filter {
+		if !contains(visible, column) {
+			return false
+		}
+	}
+	return c.SortColumn == "" || contains(sortable, c.SortColumn)
+}
+
+func (t ModelTable[T]) ColumnInfo(columns []string) []TableColumn {
+	fields := map[string]dbModelField{}
+	for _, f := range modelInfo[T]().fields {
+		fields[f.column] = f
+	}
+
+	result := make([]TableColumn, len(columns))
+	for i, c := range columns {
+		f, ok := fields[c]
+		if !ok {
+			result[i] = TableColumn{DisplayName: c, Format: t.Format[c]}
+			continue
+		}
+
+		result[i] = TableColumn{
+			DisplayName: f.label(),
+			IsSortable:  f.has("sortable"),
+			Format:      t.Format[c],
+		}
+	}
+
+	return result
+}
+
+func (t ModelTable[T]) ColumnDefault() []string {
+	if len(t.Columns) > 0 {
+		return t.Columns
+	}
+
+	columns := []string{}
+	for _, f := range modelInfo[T]().fields {
+		if !f.has("notable") {
+			columns = append(columns, f.column)
+		}
+	}
+	return columns
+}
+
+func (t ModelTable[T]) Actions() *TableActions {
+	return t.TableActions
+}
+
+func (t ModelTable[T]) DisplaySettings() TableDisplayProperties {
+	return t.Display
+}
+
+func (t ModelTable[T]) DeleteContext(ctx context.Context, ids []uint) (*ResponseAction, error) {
+	// only entries accessible through the table query can be deleted
+	ids, err := t.accessibleIDs(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	if len(ids) == 0 {
+		return ResponseRefresh(), nil
+	}
+
+	if t.BeforeDelete != nil {
+		err := t.BeforeDelete(ctx, ids)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return ResponseRefresh(), DBFromContext(ctx).Delete(new(T), ids).Error
+}
+
+// accessibleIDs returns the specified IDs of all entries returned by the table query.
+func (t ModelTable[T]) accessibleIDs(ctx context.Context, ids []uint) ([]uint, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	query, err := t.query(TableConfiguration{ctx: ctx})
+	if err != nil {
+		return nil, err
+	}
+
+	values := make([]interface{}, len(ids))
+	for i, id := range ids {
+		values[i] = id
+	}
+
+	var result []uint
+	err = query.
+		Where(clause.IN{Column: clause.PrimaryColumn, Values: values}).
+		Pluck(modelInfo[T]().table+".id", &result).Error
+	return result, err
+}
+
+// ModelForm is a generic form specification for the registered model T. The form items are
+// generated from the model fields (including a hidden "id" item and a hidden version item for
+// versioned models). All hooks are optional.
+type ModelForm[T any] struct {
+	// form name (available at '/forms/<name>')
+	FormName string
+
+	// Query can restrict the accessible entries, e.g. for access checks. Applies to reading,
+	// updating and deleting existing entries.
+	Query func(ctx context.Context, query *gorm.DB) (*gorm.DB, error)
+	// Items can adapt the generated form items, e.g. to change layout properties.
+	Items func(id string, items FormItems) (FormItems, error)
+	// BeforeSave is called after the form values are copied to the model.
+	BeforeSave func(ctx context.Context, model *T, items FormItems) error
+	// AfterSave returns the response action. Default: page refresh.
+	AfterSave func(ctx context.Context, model *T) (*ResponseAction, error)
+	// BeforeDelete is called before the model is deleted.
+	BeforeDelete func(ctx context.Context, model *T) error
+}
+
+func (f ModelForm[T]) Name() string {
+	return f.FormName
+}
+
+func (f ModelForm[T]) Read(id string) (FormItems, error) {
+	return f.ReadContext(context.Background(), id)
+}
+
+func (f ModelForm[T]) ReadContext(ctx context.Context, id string) (FormItems, error) {
+	info := modelInfo[T]()
+
+	items := FormItems{
+		{InputType: "input", InputTypeHTML: "text", Name: "id", Value: id, IsHidden: true},
+	}
+	for _, field := range info.fields {
+		if !field.has("noform") && isFormFieldType(field.typ) {
+			items = append(items, field.formItem())
+		}
+	}
+
+	if id != "" {
+		model, err := f.load(ctx, DBFromContext(ctx), id)
+		if err != nil {
+			return nil, err
+		}
+		items = DBExtractForm(modelName[T](), *model, items)
+	}
+
+	if f.Items != nil {
+		return f.Items(id, items)
+	}
+	return items, nil
+}
+
+// load returns the specified entry (if accessible, see Query).
+func (f ModelForm[T]) load(ctx context.Context, db *gorm.DB, id string) (*T, error) {
+	key, err := strconv.ParseUint(id, 10, 64)
+	if err != nil {
+		return nil, ErrorFormInvalidRequest
+	}
+
+	query := db.WithContext(ctx).Model(new(T))
+	if f.Query != nil {
+		query, err = f.Query(ctx, query)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	model := new(T)
+	err = query.First(model, key).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrorFormItemNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return model, nil
+}
+
+func (f ModelForm[T]) SaveContext(ctx context.Context, id string, items FormItems) (*ResponseAction, error) {
+	db := DBFromContext(ctx)
+
+	model := new(T)
+	if id != "" {
+		var err error
+		model, err = f.load(ctx, db, id)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	info := modelInfo[T]()
+	tr := contextTranslator(ctx)
+	err := bindModel(tr, info, model, items)
+	var bindErrors FormBindErrors
+	if errors.As(err, &bindErrors) {
+		return ResponseFormError(tr(FormMessageInvalidValues)), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if f.BeforeSave != nil {
+		err = f.BeforeSave(ctx, model, items)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = db.Save(model).Error
+	if err != nil {
+		return nil, err
+	}
+
+	action := ResponseRefresh()
+	if f.AfterSave != nil {
+		action, err = f.AfterSave(ctx, model)
+		if err != nil || action == nil {
+			return action, err
+		}
+	}
+	if action.id == "" {
+		action.WithID(reflect.ValueOf(model).Elem().FieldByName(info.columnMap["id"]).Interface())
+	}
+	return action, nil
+}
+
+func (f ModelForm[T]) DeleteContext(ctx context.Context, id string) (*ResponseAction, error) {
+	db := DBFromContext(ctx)
+
+	model, err := f.load(ctx, db, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if f.BeforeDelete != nil {
+		err = f.BeforeDelete(ctx, model)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return ResponseRefresh(), db.Delete(model).Error
+}
+
+func modelName[T any]() string {
+	return reflect.TypeOf((*T)(nil)).Elem().Name()
+}
+
+// modelInfo returns the model information of T. Panics if T is not registered.
+func modelInfo[T any]() dbModelInfo {
+	info, ok := dbModelInfos[modelName[T]()]
+	if !ok {
+		Log.PanicContext("model not registered", LogContext{"name": modelName[T]()})
+	}
+	return info
+}
+
+// formItem returns the form item of a model field.
+func (f dbModelField) formItem() FormItem {
+	return newFormItem(f.column, f.label(), f.typ, f.settings)
+}
+
+// bindModel copies the form item values to the fields of the model (pointer).
+// The version item is copied to the version field (for optimistic concurrency control).
+// Empty password items keep the stored value (see DBExtractForm).
+// Returns FormBindErrors if values cannot be converted (help texts translated using tr).
+func bindModel(tr translateFunc, info dbModelInfo, model interface{}, items FormItems) error {
+	value := reflect.ValueOf(model).Elem()
+
+	fields := map[string]dbModelField{}
+	for _, f := range info.fields {
+		fields[f.column] = f
+	}
+
+	var bindErrors FormBindErrors
+	for i, item := range items {
+		var err error
+		if item.InputTypeHTML == "password" && item.Value == "" {
+			continue
+		}
+		if f, ok := fields[item.Name]; ok {
+			err = validateFormOption(f.settings, item.Value)
+			if err == nil {
+				err = parseFormValue(value.FieldByName(f.name), item.Value, item.InputTypeHTML)
+			}
+		} else if item.Name == FormVersionItem && info.versionColumn != "" {
+			err = parseFormValue(value.FieldByName(info.columnMap[info.versionColumn]), item.Value, "")
+		}
+
+		if err != nil {
+			items[i].Help = tr(err.Error())
+			items[i].HelpClass = "is-danger"
+			bindErrors = append(bindErrors, FormBindError{Item: item.Name, Err: err})
+		}
+	}
+
+	if len(bindErrors) > 0 {
+		return bindErrors
+	}
+	return nil
+}
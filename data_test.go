@@ -0,0 +1,127 @@
+package uos
+
+import (
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"gorm.io/driver/mysql"
+	"gorm.io/driver/postgres"
+)
+
+var testLoggingOnce sync.Once
+
+// setupTestLogging initializes the framework logger (warnings and errors only).
+func setupTestLogging() {
+	testLoggingOnce.Do(func() {
+		Config.Logging.Level = "warn"
+		setupLogging()
+	})
+}
+
+// setupTestDB opens a new SQLite database (including the framework models) for the test.
+func setupTestDB(t *testing.T) {
+	t.Helper()
+	setupTestLogging()
+
+	Config.Database = DBConfiguration{File: filepath.Join(t.TempDir(), "test.db")}
+	openDataAccess()
+
+	t.Cleanup(func() {
+		if sqlDB, err := DB.DB(); err == nil {
+			sqlDB.Close()
+		}
+		DB = nil
+		Config.Database = DBConfiguration{}
+	})
+}
+
+func TestNewDialector(t *testing.T) {
+	setupTestLogging()
+	t.Setenv("UOS_TEST_DB_PASSWORD", "secret")
+
+	tests := []struct {
+		name     string
+		config   DBConfiguration
+		wantName string
+		wantDSN  string
+		isError  bool
+	}{
+		{"default driver", DBConfiguration{File: "app.db"}, "sqlite", "", false},
+		{"sqlite DSN", DBConfiguration{Driver: "sqlite", DSN: "app.db"}, "sqlite", "", false},
+		{
+			"postgres",
+			DBConfiguration{Driver: "postgres", DSN: "host=db password=${UOS_TEST_DB_PASSWORD}"},
+			"postgres", "host=db password=secret", false,
+		},
+		{
+			"mysql",
+			DBConfiguration{Driver: "mysql", DSN: "app:${UOS_TEST_DB_PASSWORD}@tcp(db)/app"},
+			"mysql", "app:secret@tcp(db)/app", false,
+		},
+		{"unknown driver", DBConfiguration{Driver: "oracle"}, "", "", true},
+		{"invalid sqlite setting", DBConfiguration{File: "app.db", JournalMode: "fast"}, "", "", true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			dialector, err := newDialector(tc.config)
+			if tc.isError {
+				if err == nil {
+					t.Error("expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if name := dialector.Name(); name != tc.wantName {
+				t.Errorf("got dialector %q, want %q", name, tc.wantName)
+			}
+
+			dsn := ""
+			switch d := dialector.(type) {
+			case *postgres.Dialector:
+				dsn = d.DSN
+			case *mysql.Dialector:
+				dsn = d.DSN
+			}
+			if dsn != tc.wantDSN {
+				t.Errorf("got DSN %q, want %q", dsn, tc.wantDSN)
+			}
+		})
+	}
+}
+
+func TestConfigureConnectionPool(t *testing.T) {
+	setupTestDB(t)
+
+	tests := []struct {
+		name    string
+		config  DBConfiguration
+		isError bool
+	}{
+		{"defaults", DBConfiguration{}, false},
+		{"limits", DBConfiguration{MaxOpenConns: 4, MaxIdleConns: 2, ConnMaxLifetime: "1h", ConnMaxIdleTime: "5m"}, false},
+		{"invalid lifetime", DBConfiguration{ConnMaxLifetime: "1 hour"}, true},
+		{"invalid idle time", DBConfiguration{ConnMaxIdleTime: "5 minutes"}, true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := configureConnectionPool(DB, tc.config)
+			if isError := err != nil; isError != tc.isError {
+				t.Errorf("got error %v, want error %t", err, tc.isError)
+			}
+		})
+	}
+
+	sqlDB, err := DB.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats := sqlDB.Stats(); stats.MaxOpenConnections != 4 {
+		t.Errorf("got max open connections %d, want 4", stats.MaxOpenConnections)
+	}
+}
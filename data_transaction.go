@@ -0,0 +1,91 @@
+package uos
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"gorm.io/gorm"
+)
+
+const ctxDBTransaction string = "ctxDBTransaction"
+
+// DBFromContext returns the database connection for the given context. Inside a request
+// transaction (see DBConfiguration.RequestTransactions) the transaction is returned.
+func DBFromContext(ctx context.Context) *gorm.DB {
+	if tx, ok := ctx.Value(ctxDBTransaction).(*gorm.DB); ok {
+		return tx
+	}
+
+	return DB.WithContext(ctx)
+}
+
+// specWithoutTransaction is implemented by specs not executed inside request transactions: the
+// adapters of specs without context parameter (e.g. FormSpecSave) using the global DB
+// connection and actions using separate connections (e.g. database backups).
+type specWithoutTransaction interface {
+	withoutTransaction()
+}
+
+// inRequestTransaction executes f inside a database transaction (if request transactions are
+// enabled and the spec supports them). The transaction is available through the request
+// context passed to f (DBContext). It is committed if f returns a successful response action
+// - it is rolled back if f returns an error, a form error response or panics.
+func inRequestTransaction(
+	r *http.Request, spec interface{},
+	f func(r *http.Request) (*ResponseAction, error),
+) (action *ResponseAction, err error) {
+	if !Config.Database.RequestTransactions {
+		return f(r)
+	}
+	if _, ok := spec.(specWithoutTransaction); ok {
+		// changes would not be part of the transaction (and could wait for the connection
+		// held by the transaction)
+		return f(r)
+	}
+	if _, ok := r.Context().Value(ctxDBTransaction).(*gorm.DB); ok {
+		// already inside a request transaction
+		return f(r)
+	}
+
+	tx := DB.WithContext(r.Context()).Begin()
+	if tx.Error != nil {
+		return nil, tx.Error
+	}
+	Log.TraceR(r, "begin request transaction")
+
+	defer func() {
+		if p := recover(); p != nil {
+			rollbackRequestTransaction(r, tx, "panic")
+			panic(p)
+		}
+	}()
+
+	action, err = f(r.WithContext(context.WithValue(r.Context(), ctxDBTransaction, tx)))
+	switch {
+	case err != nil:
+		rollbackRequestTransaction(r, tx, "error")
+		return nil, err
+	case action != nil && action.isFormError:
+		rollbackRequestTransaction(r, tx, "form error")
+		return action, nil
+	}
+
+	err = tx.Commit().Error
+	if err != nil {
+		return nil, fmt.Errorf("could not commit request transaction: %w", err)
+	}
+	Log.TraceR(r, "commit request transaction")
+
+	return action, nil
+}
+
+func rollbackRequestTransaction(r *http.Request, tx *gorm.DB, reason string) {
+	err := tx.Rollback().Error
+	if err != nil {
+		Log.ErrorObjR(r, "could not rollback request transaction", err)
+		return
+	}
+
+	Log.DebugContextR(r, "rollback request transaction", LogContext{"reason": reason})
+}
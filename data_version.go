@@ -0,0 +1,141 @@
+package uos
+
+import (
+	"fmt"
+	"net/url"
+	"reflect"
+	"strconv"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// FormVersionItem is the name of the hidden form item containing the version of a record
+// with optimistic concurrency control (model field tagged with `uos:"version"`). The form
+// handler compares the posted version with the current record before saving - concurrent saves
+// are only detected if Save copies the posted version into the version field of the model
+// before updating it (the update then fails with ErrorVersionConflict). ModelForm copies the
+// version automatically.
+const FormVersionItem = "_version"
+
+const dbVersionCheck = "uos:version_check"
+
+// registerDBVersioning adds optimistic concurrency control to updates of models with a version
+// field: the update only succeeds if the stored version matches the version of the model and
+// increments the version. Otherwise ErrorVersionConflict is returned.
+func registerDBVersioning(db *gorm.DB) {
+	callbacks := db.Callback()
+
+	err := callbacks.Update().Before("gorm:update").Register("uos:version_before_update", dbVersionBefore)
+	if err == nil {
+		err = callbacks.Update().After("gorm:update").Register("uos:version_after_update", dbVersionAfter)
+	}
+	if err != nil {
+		Log.PanicError("could not register DB versioning callbacks", err)
+	}
+}
+
+func dbVersionBefore(db *gorm.DB) {
+	stmt := db.Statement
+	if db.Error != nil || stmt.Schema == nil || stmt.ReflectValue.Kind() != reflect.Struct {
+		return
+	}
+
+	column := dbModelInfos[stmt.Schema.Name].versionColumn
+	if column == "" {
+		return
+	}
+
+	// only single records (identified by primary key) are checked - batch updates are not
+	for _, pf := range stmt.Schema.PrimaryFields {
+		if _, isZero := pf.ValueOf(stmt.Context, stmt.ReflectValue); isZero {
+			return
+		}
+	}
+
+	field := stmt.Schema.LookUpField(column)
+	value, _ := field.ValueOf(stmt.Context, stmt.ReflectValue)
+	version, err := strconv.ParseUint(fmt.Sprint(value), 10, 64)
+	if err != nil {
+		_ = db.AddError(fmt.Errorf("invalid version of model '%s': %w", stmt.Schema.Name, err))
+		return
+	}
+
+	stmt.AddClause(clause.Where{Exprs: []clause.Expression{
+		clause.Eq{Column: clause.Column{Table: clause.CurrentTable, Name: column}, Value: version},
+	}})
+	stmt.SetColumn(column, version+1)
+	db.InstanceSet(dbVersionCheck, true)
+}
+
+func dbVersionAfter(db *gorm.DB) {
+	if _, ok := db.InstanceGet(dbVersionCheck); !ok {
+		return
+	}
+
+	if db.Error == nil && db.RowsAffected == 0 {
+		_ = db.AddError(ErrorVersionConflict)
+	}
+}
+
+// addVersionItem adds the posted record version as hidden item (if the form contains no
+// version item yet).
+func (fi *FormItems) addVersionItem(v url.Values) {
+	if !v.Has(FormVersionItem) || fi.Get(FormVersionItem) != nil {
+		return
+	}
+
+	*fi = append(*fi, FormItem{
+		InputType:     "input",
+		InputTypeHTML: "text",
+		Name:          FormVersionItem,
+		IsHidden:      true,
+	})
+}
+
+// formConflict describes changes of a record since the form was loaded.
+type formConflict struct {
+	// ID of the changed record (used to reload the form)
+	ID string `json:"id"`
+	// current values of changed form items
+	Changes []formChange `json:"changes"`
+
+	// text of the reload button
+	ReloadText string `json:"-"`
+}
+
+type formChange struct {
+	Label string `json:"label"`
+	Value string `json:"value"`
+}
+
+// versionConflict compares the submitted record version with the current record. Returns nil
+// if the versions match or the form has no version item.
+func (fi FormItems) versionConflict(id string, current FormItems) *formConflict {
+	submitted := fi.Get(FormVersionItem)
+	currentVersion := current.Get(FormVersionItem)
+	if submitted == nil || currentVersion == nil || submitted.Value == currentVersion.Value {
+		return nil
+	}
+
+	return fi.conflict(id, current)
+}
+
+// conflict returns all (visible) items, whose current value differs from the submitted value.
+func (fi FormItems) conflict(id string, current FormItems) *formConflict {
+	result := &formConflict{ID: id}
+	for _, item := range fi {
+		c := current.Get(item.Name)
+		if item.IsHidden || c == nil || c.Value == item.Value {
+			continue
+		}
+
+		label := item.Label
+		if label == "" {
+			label = item.Name
+		}
+		result.Changes = append(result.Changes, formChange{Label: label, Value: c.Value})
+	}
+
+	return result
+}
@@ -0,0 +1,136 @@
+package uos
+
+import (
+	"errors"
+	"net/url"
+	"reflect"
+	"testing"
+
+	"gorm.io/gorm"
+)
+
+type testVersionedModel struct {
+	gorm.Model
+
+	Name    string
+	Version uint `uos:"version"`
+}
+
+func TestDBVersioning(t *testing.T) {
+	setupTestDB(t)
+	RegisterDBModels(testVersionedModel{})
+
+	record := testVersionedModel{Name: "initial"}
+	if err := DB.Create(&record).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	var first, second testVersionedModel
+	DB.First(&first, record.ID)
+	DB.First(&second, record.ID)
+
+	first.Name = "first"
+	if err := DB.Save(&first).Error; err != nil {
+		t.Fatalf("first update failed: %v", err)
+	}
+	if first.Version != record.Version+1 {
+		t.Errorf("got version %d, want %d", first.Version, record.Version+1)
+	}
+
+	second.Name = "second"
+	if err := DB.Save(&second).Error; !errors.Is(err, ErrorVersionConflict) {
+		t.Fatalf("got error %v, want version conflict", err)
+	}
+
+	var stored testVersionedModel
+	DB.First(&stored, record.ID)
+	if stored.Name != "first" || stored.Version != first.Version {
+		t.Errorf("got stored record %q (version %d), want first update", stored.Name, stored.Version)
+	}
+
+	// batch updates are not checked
+	err := DB.Model(&testVersionedModel{}).Where("name = ?", "first").Update("name", "batch").Error
+	if err != nil {
+		t.Errorf("batch update failed: %v", err)
+	}
+}
+
+func TestFormItemsVersionConflict(t *testing.T) {
+	form := func(version, name, comment string) FormItems {
+		items := FormItems{
+			{Name: "name", Label: "Name", Value: name},
+			{Name: "comment", Value: comment},
+			{Name: "secret", Value: name, IsHidden: true},
+		}
+		if version != "" {
+			items = append(items, FormItem{Name: FormVersionItem, Value: version, IsHidden: true})
+		}
+		return items
+	}
+
+	tests := []struct {
+		name        string
+		submitted   FormItems
+		current     FormItems
+		isConflict  bool
+		wantChanges []formChange
+	}{
+		{"same version", form("1", "a", "x"), form("1", "b", "y"), false, nil},
+		{"no submitted version", form("", "a", "x"), form("2", "b", "y"), false, nil},
+		{"unversioned record", form("1", "a", "x"), form("", "b", "y"), false, nil},
+		{
+			"changed record",
+			form("1", "a", "x"), form("2", "b", "y"),
+			true, []formChange{{Label: "Name", Value: "b"}, {Label: "comment", Value: "y"}},
+		},
+		{"changed version only", form("1", "a", "x"), form("2", "a", "x"), true, nil},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			conflict := tc.submitted.versionConflict("7", tc.current)
+			if !tc.isConflict {
+				if conflict != nil {
+					t.Errorf("got conflict %+v, want none", conflict)
+				}
+				return
+			}
+
+			if conflict == nil {
+				t.Fatal("expected conflict")
+			}
+			if conflict.ID != "7" {
+				t.Errorf("got ID %q, want 7", conflict.ID)
+			}
+			if !reflect.DeepEqual(conflict.Changes, tc.wantChanges) {
+				t.Errorf("got changes %+v, want %+v", conflict.Changes, tc.wantChanges)
+			}
+		})
+	}
+}
+
+func TestFormItemsAddVersionItem(t *testing.T) {
+	tests := []struct {
+		name      string
+		items     FormItems
+		values    url.Values
+		wantItems int
+	}{
+		{"no posted version", FormItems{{Name: "name"}}, url.Values{"name": {"a"}}, 1},
+		{"posted version", FormItems{{Name: "name"}}, url.Values{FormVersionItem: {"3"}}, 2},
+		{"existing version item", FormItems{{Name: FormVersionItem}}, url.Values{FormVersionItem: {"3"}}, 1},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			items := tc.items
+			items.addVersionItem(tc.values)
+			if len(items) != tc.wantItems {
+				t.Fatalf("got %d items, want %d", len(items), tc.wantItems)
+			}
+			if item := items.Get(FormVersionItem); tc.wantItems == 2 && (item == nil || !item.IsHidden) {
+				t.Errorf("version item missing or not hidden: %+v", item)
+			}
+		})
+	}
+}
@@ -12,7 +12,7 @@ import (
 type AppUser struct {
 	gorm.Model
 
-	Name     string `gorm:"unique"`
+	Name     string `gorm:"unique;size:255"`
 	Language string
 
 	PasswordHash string
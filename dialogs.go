@@ -116,6 +116,13 @@ func getDialogHandlerFunc(dialogs []DialogSpec) AppRequestHandler {
 			return
 		}
 
+		addComponentLogFields(r, "dialog", dialogName)
+		componentRequest("dialog", dialogName)
+		defer startComponentTimer("dialog", dialogName, "total").stop()
+
+		r, span := startSpan(r, "dialog "+dialogName)
+		defer span.End()
+
 		// process request
 		switch r.Method {
 		case http.MethodGet:
@@ -133,6 +140,7 @@ func renderDialog(w http.ResponseWriter, r *http.Request, name string, spec Dial
 	var content bytes.Buffer
 	err := renderTemplate(&content, r, name, map[string]string{"ID": id}, dialogTemplateName)
 	if err != nil {
+		componentEvent("dialog", name, "render_error")
 		Log.ErrorContextR(
 			r, "could not render page content template",
 			LogContext{"name": name, "error": err},
@@ -153,6 +161,7 @@ func renderDialog(w http.ResponseWriter, r *http.Request, name string, spec Dial
 
 	err = renderInternalTemplate(w, r, "dialog", data)
 	if err != nil {
+		componentEvent("dialog", name, "render_error")
 		Log.ErrorContextR(
 			r, "could not render dialog",
 			LogContext{"name": name, "error": err},
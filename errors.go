@@ -15,8 +15,13 @@ var (
 	// ErrorFormInvalidRequest is returned if the form request parameters are invalid
 	ErrorFormInvalidRequest = errors.New("invalid form request")
 
+	// ErrorVersionConflict is returned if a record was changed since it was read (optimistic locking)
+	ErrorVersionConflict = errors.New("record was changed concurrently")
+
 	// ErrorTableInvaliRequest is returned if the table request parameters are invalid
 	ErrorTableInvalidRequest = errors.New("invalid table request")
+	// ErrorTableForbidden is returned if the user is not allowed to access the table
+	ErrorTableForbidden = errors.New("table access forbidden")
 
 	// ErrorInvalidPassword is returned if user authentication credentials are invalid
 	ErrorInvalidPassword = errors.New("invalid user credentials")
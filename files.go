@@ -0,0 +1,536 @@
+package uos
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// FileStorage describes a storage for file contents (e.g. uploaded files). File meta data
+// (name, type, size, owner) is stored in the database.
+type FileStorage interface {
+	// Save stores the content under the given key.
+	Save(ctx context.Context, key string, content io.Reader) error
+	// Open returns the content stored under the given key.
+	Open(ctx context.Context, key string) (io.ReadCloser, error)
+	// Delete removes the content stored under the given key.
+	Delete(ctx context.Context, key string) error
+}
+
+// StoredFile describes a file in the file storage.
+type StoredFile struct {
+	gorm.Model
+
+	Key         string `gorm:"uniqueIndex;size:64"`
+	Name        string
+	ContentType string
+	Size        int64
+	OwnerID     uint
+
+	// uploaded with a form that is not saved yet (removed after pendingFileMaxAge)
+	IsPending bool `gorm:"index"`
+}
+
+func (StoredFile) TableName() string {
+	return "internal_files"
+}
+
+// URL returns the download URL of the file (see FileHandler).
+func (f StoredFile) URL() string {
+	return "/files/" + f.Key
+}
+
+var fileStorage FileStorage
+
+// maximum age of pending uploads (equal to the maximum age of wizard states)
+const pendingFileMaxAge = formWizardMaxAge * time.Second
+
+var pendingFileCleanup = struct {
+	stop chan struct{}
+}{}
+
+// SetFileStorage replaces the file storage, e.g. to use MemoryFileStorage in tests.
+func SetFileStorage(storage FileStorage) {
+	fileStorage = storage
+}
+
+func setupFileStorage() {
+	dir := Config.Files.Dir
+	if dir == "" {
+		dir = "files"
+	}
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(Config.BaseDir, dir)
+	}
+
+	Log.InfoContext("use local file storage", LogContext{"dir": dir})
+	fileStorage = LocalFileStorage(dir)
+
+	pendingFileCleanup.stop = make(chan struct{})
+	go runPendingFileCleanup(time.Hour, pendingFileCleanup.stop)
+}
+
+func cleanupFileStorage() {
+	if pendingFileCleanup.stop != nil {
+		close(pendingFileCleanup.stop)
+		pendingFileCleanup.stop = nil
+	}
+}
+
+func runPendingFileCleanup(interval time.Duration, stop chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			err := deletePendingFiles(context.Background(), time.Now().Add(-pendingFileMaxAge))
+			if err != nil {
+				Log.ErrorObj("could not remove pending files", err)
+			}
+		case <-stop:
+			return
+		}
+	}
+}
+
+// deletePendingFiles removes uploads of forms that were never saved (created before the
+// given time).
+func deletePendingFiles(ctx context.Context, before time.Time) error {
+	var files []StoredFile
+	err := DBFromContext(ctx).
+		Where("is_pending = ? AND created_at < ?", true, before).
+		Find(&files).Error
+	if err != nil {
+		return err
+	}
+
+	for _, file := range files {
+		err = DeleteFile(ctx, file.Key)
+		if err != nil {
+			return err
+		}
+	}
+	if len(files) > 0 {
+		Log.DebugContext("removed pending files", LogContext{"count": len(files)})
+	}
+
+	return nil
+}
+
+// StoreFile saves the content as new file owned by the user of the given request.
+func StoreFile(r *http.Request, name, contentType string, content io.Reader) (StoredFile, error) {
+	return storeFile(r, name, contentType, content, false)
+}
+
+func storeFile(r *http.Request, name, contentType string, content io.Reader, isPending bool) (StoredFile, error) {
+	key, err := newFileKey()
+	if err != nil {
+		return StoredFile{}, err
+	}
+
+	counter := &countingReader{r: content}
+	err = fileStorage.Save(r.Context(), key, counter)
+	if err != nil {
+		return StoredFile{}, err
+	}
+
+	file := StoredFile{
+		Key:         key,
+		Name:        filepath.Base(name),
+		ContentType: contentType,
+		Size:        counter.n,
+		IsPending:   isPending,
+	}
+	if user, ok := ContextUser(r.Context()); ok {
+		file.OwnerID = user.ID
+	}
+
+	err = DBContext(r).Create(&file).Error
+	if err != nil {
+		_ = fileStorage.Delete(r.Context(), key)
+		return StoredFile{}, err
+	}
+
+	Log.DebugContextR(r, "stored file", LogContext{"key": key, "name": file.Name, "size": file.Size})
+	return file, nil
+}
+
+// GetFile returns the meta data of the specified file.
+func GetFile(ctx context.Context, key string) (StoredFile, error) {
+	var file StoredFile
+	err := DBFromContext(ctx).Where(&StoredFile{Key: key}).First(&file).Error
+	return file, err
+}
+
+// OpenFile returns meta data and content of the specified file. The content must be closed.
+func OpenFile(ctx context.Context, key string) (StoredFile, io.ReadCloser, error) {
+	file, err := GetFile(ctx, key)
+	if err != nil {
+		return StoredFile{}, nil, err
+	}
+
+	content, err := fileStorage.Open(ctx, key)
+	return file, content, err
+}
+
+// DeleteFile removes the specified file.
+func DeleteFile(ctx context.Context, key string) error {
+	err := DBFromContext(ctx).Where(&StoredFile{Key: key}).Delete(&StoredFile{}).Error
+	if err != nil {
+		return err
+	}
+
+	return fileStorage.Delete(ctx, key)
+}
+
+func newFileKey() (string, error) {
+	b := make([]byte, 16)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func isValidFileKey(key string) bool {
+	if len(key) != 32 {
+		return false
+	}
+	_, err := hex.DecodeString(key)
+	return err == nil
+}
+
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// FileAccessFunc decides whether the user of the given request can download the file.
+type FileAccessFunc func(r *http.Request, file StoredFile) bool
+
+// FileHandler returns a handler for the "/files/" route providing downloads of stored files.
+// Downloads require authentication. If access is nil, users can only download their own files
+// (uploaded by them) - otherwise the function decides. The handler can be activated using
+// RegisterAppRequestHandlers.
+func FileHandler(access FileAccessFunc) AppRequestHandlerMapping {
+	return AppRequestHandlerMapping{
+		Route:   "/files/",
+		Handler: getFileHandlerFunc(access),
+		Options: AppRequestHandlerOptions{
+			NoSitemap: true,
+		},
+	}.Internal()
+}
+
+func getFileHandlerFunc(access FileAccessFunc) AppRequestHandler {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := getElementName("files", r.URL.Path)
+		if r.Method != http.MethodGet || !isValidFileKey(key) {
+			RespondNotFound(w)
+			return
+		}
+
+		user, ok := ContextUser(r.Context())
+		if !ok {
+			RespondForbidden(w)
+			return
+		}
+
+		file, content, err := OpenFile(r.Context(), key)
+		if errors.Is(err, gorm.ErrRecordNotFound) || errors.Is(err, os.ErrNotExist) {
+			RespondNotFound(w)
+			return
+		}
+		if err != nil {
+			Log.ErrorObjR(r, "could not open file", err)
+			RespondInternalServerError(w)
+			return
+		}
+		defer content.Close()
+
+		if access == nil && file.OwnerID != user.ID || access != nil && !access(r, file) {
+			RespondForbidden(w)
+			return
+		}
+
+		contentType := file.ContentType
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("Content-Length", fmt.Sprint(file.Size))
+		w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": file.Name}))
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+
+		_, err = io.Copy(w, content)
+		if err != nil {
+			Log.WarnErrorR(r, "could not send file", err)
+		}
+	}
+}
+
+// isFileTypeAllowed checks the file against a list of allowed types. Types are MIME types
+// ("application/pdf"), MIME type groups ("image/*") or file extensions (".pdf").
+func isFileTypeAllowed(types []string, name, contentType string) bool {
+	if len(types) == 0 {
+		return true
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	extension := strings.ToLower(filepath.Ext(name))
+	for _, t := range types {
+		t = strings.ToLower(t)
+		switch {
+		case strings.HasPrefix(t, "."):
+			if extension == t {
+				return true
+			}
+		case strings.HasSuffix(t, "/*"):
+			if strings.HasPrefix(mediaType, strings.TrimSuffix(t, "*")) {
+				return true
+			}
+		case mediaType == t:
+			return true
+		}
+	}
+
+	return false
+}
+
+type localFileStorage struct {
+	dir string
+}
+
+// LocalFileStorage returns a file storage using the specified directory.
+func LocalFileStorage(dir string) FileStorage {
+	return localFileStorage{dir: dir}
+}
+
+func (s localFileStorage) path(key string) string {
+	// keys are hex strings - distribute files to sub directories
+	return filepath.Join(s.dir, key[:2], key)
+}
+
+func (s localFileStorage) Save(ctx context.Context, key string, content io.Reader) error {
+	path := s.path(key)
+	err := os.MkdirAll(filepath.Dir(path), 0o750)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o640)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(f, content)
+	if err != nil {
+		f.Close()
+		os.Remove(path)
+		return err
+	}
+
+	return f.Close()
+}
+
+func (s localFileStorage) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	return os.Open(s.path(key))
+}
+
+func (s localFileStorage) Delete(ctx context.Context, key string) error {
+	err := os.Remove(s.path(key))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+type memoryFileStorage struct {
+	sync.RWMutex
+
+	files map[string][]byte
+}
+
+// MemoryFileStorage returns a file storage keeping all files in memory (e.g. for tests).
+func MemoryFileStorage() FileStorage {
+	return &memoryFileStorage{files: map[string][]byte{}}
+}
+
+func (s *memoryFileStorage) Save(ctx context.Context, key string, content io.Reader) error {
+	data, err := io.ReadAll(content)
+	if err != nil {
+		return err
+	}
+
+	s.Lock()
+	defer s.Unlock()
+
+	s.files[key] = data
+	return nil
+}
+
+func (s *memoryFileStorage) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	s.RLock()
+	defer s.RUnlock()
+
+	data, ok := s.files[key]
+	if !ok {
+		return nil, os.ErrNotExist
+	}
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
+
+func (s *memoryFileStorage) Delete(ctx context.Context, key string) error {
+	s.Lock()
+	defer s.Unlock()
+
+	delete(s.files, key)
+	return nil
+}
+
+// storeFiles saves the uploaded files of all file items (as pending files) and sets the file
+// keys as posted values. Posted file keys (without upload) must be the current values of the
+// items or pending files of the user. Returns validation messages by item name for files
+// violating the item constraints. Must be called before the posted values are assigned.
+func (fi FormItems) storeFiles(r *http.Request) map[string]string {
+	messages := map[string]string{}
+
+	for _, item := range fi {
+		if item.InputType != "file" {
+			continue
+		}
+
+		var headers []*multipart.FileHeader
+		if r.MultipartForm != nil {
+			headers = r.MultipartForm.File[item.Name]
+		}
+		if len(headers) == 0 {
+			if key := r.Form.Get(item.Name); key != "" && key != item.Value && !isPendingUserFile(r, key) {
+				messages[item.Name] = TR(r, FormMessageInvalidFile)
+			}
+			continue
+		}
+
+		file, message, err := storeUploadedFile(r, headers[0], item.Constraints)
+		if err != nil {
+			Log.ErrorObjR(r, "could not store uploaded file", err)
+			message = TR(r, FormMessageUploadFailed)
+		}
+		if message != "" {
+			messages[item.Name] = message
+			continue
+		}
+
+		r.Form.Set(item.Name, file.Key)
+	}
+
+	return messages
+}
+
+// isPendingUserFile checks whether the file is a pending upload of the user of the request.
+func isPendingUserFile(r *http.Request, key string) bool {
+	if !isValidFileKey(key) {
+		return false
+	}
+
+	file, err := GetFile(r.Context(), key)
+	if err != nil {
+		return false
+	}
+
+	var userID uint
+	if user, ok := ContextUser(r.Context()); ok {
+		userID = user.ID
+	}
+	return file.IsPending && file.OwnerID == userID
+}
+
+// attachFiles marks the uploaded files of the saved items as permanent and removes replaced
+// files. Errors are logged - the saved form is not affected.
+func (fi FormItems) attachFiles(r *http.Request) {
+	for _, item := range fi {
+		if item.InputType != "file" || item.initial == nil || item.Value == item.initial.Value {
+			continue
+		}
+
+		if item.Value != "" {
+			err := DBContext(r).Model(&StoredFile{}).
+				Where(&StoredFile{Key: item.Value}).
+				Update("is_pending", false).Error
+			if err != nil {
+				Log.ErrorObjR(r, "could not attach file", err)
+			}
+		}
+		if item.initial.Value != "" {
+			err := DeleteFile(r.Context(), item.initial.Value)
+			if err != nil {
+				Log.ErrorObjR(r, "could not remove replaced file", err)
+			}
+		}
+	}
+}
+
+// setFileErrors marks file items with invalid uploads. Returns false if any upload was invalid.
+func (fi FormItems) setFileErrors(messages map[string]string) bool {
+	for i, item := range fi {
+		if message, ok := messages[item.Name]; ok {
+			fi[i].Help = message
+			fi[i].HelpClass = "is-danger"
+		}
+	}
+
+	return len(messages) == 0
+}
+
+// storeUploadedFile checks the uploaded file against the constraints and stores it. Returns
+// a (translated) validation message if the file is invalid.
+func storeUploadedFile(r *http.Request, header *multipart.FileHeader, c *FormItemConstraints) (StoredFile, string, error) {
+	if c != nil && c.MaxFileSize > 0 && header.Size > c.MaxFileSize {
+		return StoredFile{}, TR(r, FormMessageFileTooLarge, c.MaxFileSize/1024), nil
+	}
+
+	f, err := header.Open()
+	if err != nil {
+		return StoredFile{}, "", err
+	}
+	defer f.Close()
+
+	// determine content type from content (fallback: declared type)
+	head := make([]byte, 512)
+	n, err := io.ReadFull(f, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return StoredFile{}, "", err
+	}
+	contentType := http.DetectContentType(head[:n])
+	if declared := header.Header.Get("Content-Type"); contentType == "application/octet-stream" && declared != "" {
+		contentType = declared
+	}
+
+	if c != nil && !isFileTypeAllowed(c.FileTypes, header.Filename, contentType) {
+		return StoredFile{}, TR(r, FormMessageFileTypeInvalid), nil
+	}
+
+	file, err := storeFile(r, header.Filename, contentType, io.MultiReader(bytes.NewReader(head[:n]), f), true)
+	return file, "", err
+}
@@ -0,0 +1,167 @@
+package uos
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestIsFileTypeAllowed(t *testing.T) {
+	tests := []struct {
+		name        string
+		types       []string
+		file        string
+		contentType string
+		isAllowed   bool
+	}{
+		{"no restriction", nil, "a.exe", "application/octet-stream", true},
+		{"extension", []string{".pdf"}, "a.PDF", "application/octet-stream", true},
+		{"other extension", []string{".pdf"}, "a.txt", "application/pdf", false},
+		{"MIME type", []string{"application/pdf"}, "a", "application/pdf", true},
+		{"MIME type with parameters", []string{"text/plain"}, "a", "text/plain; charset=utf-8", true},
+		{"MIME type group", []string{"image/*"}, "a", "image/png", true},
+		{"other MIME type group", []string{"image/*"}, "a", "text/plain", false},
+		{"multiple types", []string{".pdf", "image/*"}, "a.png", "image/png", true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if isAllowed := isFileTypeAllowed(tc.types, tc.file, tc.contentType); isAllowed != tc.isAllowed {
+				t.Errorf("got %t, want %t", isAllowed, tc.isAllowed)
+			}
+		})
+	}
+}
+
+// setupTestFileStorage opens a test database and keeps stored files in memory.
+func setupTestFileStorage(t *testing.T) {
+	t.Helper()
+	setupTestDB(t)
+
+	storage := fileStorage
+	SetFileStorage(MemoryFileStorage())
+	t.Cleanup(func() { SetFileStorage(storage) })
+}
+
+// newTestUserRequest returns a request of the specified user (0: anonymous).
+func newTestUserRequest(method, target string, userID uint) *http.Request {
+	r := httptest.NewRequest(method, target, nil)
+	if userID > 0 {
+		user := AppUser{}
+		user.ID = userID
+		r = r.WithContext(context.WithValue(r.Context(), ctxAppUser, user))
+	}
+	return r
+}
+
+func TestFileHandler(t *testing.T) {
+	setupTestFileStorage(t)
+
+	file, err := StoreFile(newTestUserRequest(http.MethodPost, "/", 1), "dir/report.txt", "text/plain", strings.NewReader("content"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if file.Name != "report.txt" || file.Size != 7 || file.OwnerID != 1 || file.IsPending {
+		t.Fatalf("unexpected stored file: %+v", file)
+	}
+
+	allowAll := func(r *http.Request, file StoredFile) bool { return true }
+
+	tests := []struct {
+		name       string
+		method     string
+		key        string
+		userID     uint
+		access     FileAccessFunc
+		wantStatus int
+	}{
+		{"owner", http.MethodGet, file.Key, 1, nil, http.StatusOK},
+		{"other user", http.MethodGet, file.Key, 2, nil, http.StatusForbidden},
+		{"other user with access", http.MethodGet, file.Key, 2, allowAll, http.StatusOK},
+		{"anonymous", http.MethodGet, file.Key, 0, allowAll, http.StatusForbidden},
+		{"unknown file", http.MethodGet, strings.Repeat("0", 32), 1, nil, http.StatusNotFound},
+		{"invalid key", http.MethodGet, "../test.db", 1, nil, http.StatusNotFound},
+		{"invalid method", http.MethodPost, file.Key, 1, nil, http.StatusNotFound},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			getFileHandlerFunc(tc.access)(w, newTestUserRequest(tc.method, "/files/"+tc.key, tc.userID))
+
+			if w.Code != tc.wantStatus {
+				t.Fatalf("got status %d, want %d", w.Code, tc.wantStatus)
+			}
+			if tc.wantStatus != http.StatusOK {
+				return
+			}
+			if body := w.Body.String(); body != "content" {
+				t.Errorf("got content %q", body)
+			}
+			if disposition := w.Header().Get("Content-Disposition"); disposition != `attachment; filename=report.txt` {
+				t.Errorf("got content disposition %q", disposition)
+			}
+		})
+	}
+}
+
+func TestDeletePendingFiles(t *testing.T) {
+	setupTestFileStorage(t)
+
+	r := newTestUserRequest(http.MethodPost, "/", 1)
+	pending, err := storeFile(r, "pending.txt", "text/plain", strings.NewReader("a"), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stored, err := storeFile(r, "stored.txt", "text/plain", strings.NewReader("b"), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// recent pending files are kept
+	err = deletePendingFiles(context.Background(), time.Now().Add(-time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := GetFile(context.Background(), pending.Key); err != nil {
+		t.Errorf("recent pending file removed: %v", err)
+	}
+
+	err = deletePendingFiles(context.Background(), time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		key      string
+		isExists bool
+	}{
+		{"pending file", pending.Key, false},
+		{"stored file", stored.Key, true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := GetFile(context.Background(), tc.key)
+			if isExists := err == nil; isExists != tc.isExists {
+				t.Errorf("got file exists %t, want %t", isExists, tc.isExists)
+			}
+
+			_, content, err := OpenFile(context.Background(), tc.key)
+			if content != nil {
+				defer content.Close()
+				data, _ := io.ReadAll(content)
+				if !tc.isExists || len(data) == 0 {
+					t.Errorf("got content %q", data)
+				}
+			} else if tc.isExists {
+				t.Errorf("content missing: %v", err)
+			}
+		})
+	}
+}
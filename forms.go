@@ -1,11 +1,18 @@
 package uos
 
 import (
-	"fmt"
+	"context"
+	"errors"
 	"net/http"
+	"net/mail"
 	"net/url"
+	"reflect"
+	"regexp"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
 )
 
 // FormSpec describes the interface every web application form must implement.
@@ -21,22 +28,107 @@ type FormSpecRead interface {
 	Read(id string) (FormItems, error)
 }
 
-// FormSpecSave must be implemented by a web application form to support POST requests.
+// FormSpecSave must be implemented by a web application form to support POST requests. Saving
+// is not executed inside the request transaction (see FormSpecSaveContext).
 type FormSpecSave interface {
 	// Read returns the list of form items. If an id is specified, the form items for an existing
 	// entity is returned.
 	Read(id string) (FormItems, error)
-	// Save writes the specified items to the database.
+	// Save writes the specified items to the database. Versioned models: the version item must
+	// be copied into the model (see FormVersionItem).
 	Save(id string, items FormItems) (*ResponseAction, error)
 }
 
 // FormSpecDelete must be implemented by a web application form to support DELETE requests.
+// Deleting is not executed inside the request transaction (see FormSpecDeleteContext).
 type FormSpecDelete interface {
 	// Delete removes the specified item from the database.
 	Delete(id string) (*ResponseAction, error)
 }
 
+// FormSpecSaveContext can be implemented instead of FormSpecSave to access the request context
+// while saving, e.g. to use the request transaction (DBFromContext).
+type FormSpecSaveContext interface {
+	// Read returns the list of form items. If an id is specified, the form items for an existing
+	// entity is returned.
+	Read(id string) (FormItems, error)
+	// SaveContext writes the specified items to the database. Versioned models: the version
+	// item must be copied into the model (see FormVersionItem).
+	SaveContext(ctx context.Context, id string, items FormItems) (*ResponseAction, error)
+}
+
+// FormSpecDeleteContext can be implemented instead of FormSpecDelete to access the request
+// context while deleting, e.g. to use the request transaction (DBFromContext).
+type FormSpecDeleteContext interface {
+	// DeleteContext removes the specified item from the database.
+	DeleteContext(ctx context.Context, id string) (*ResponseAction, error)
+}
+
+// FormSpecReadContext can be implemented in addition to Read to access the request context
+// while reading, e.g. for access checks. Requests use ReadContext instead of Read.
+type FormSpecReadContext interface {
+	// ReadContext returns the list of form items (see FormSpecRead).
+	ReadContext(ctx context.Context, id string) (FormItems, error)
+}
+
+// readForm returns the form items using ReadContext (if implemented) or Read.
+func readForm(r *http.Request, spec FormSpecRead, id string) (FormItems, error) {
+	if adapter, ok := spec.(formSaveAdapter); ok {
+		spec = adapter.FormSpecSave
+	}
+	if f, ok := spec.(FormSpecReadContext); ok {
+		return f.ReadContext(r.Context(), id)
+	}
+	return spec.Read(id)
+}
+
+type formSaveAdapter struct {
+	FormSpecSave
+}
+
+func (a formSaveAdapter) SaveContext(_ context.Context, id string, items FormItems) (*ResponseAction, error) {
+	return a.Save(id, items)
+}
+
+func (a formSaveAdapter) withoutTransaction() {}
+
+type formDeleteAdapter struct {
+	FormSpecDelete
+}
+
+func (a formDeleteAdapter) DeleteContext(_ context.Context, id string) (*ResponseAction, error) {
+	return a.Delete(id)
+}
+
+func (a formDeleteAdapter) withoutTransaction() {}
+
+func getFormSave(spec FormSpec) (FormSpecSaveContext, bool) {
+	if f, ok := spec.(FormSpecSaveContext); ok {
+		return f, true
+	}
+	if f, ok := spec.(FormSpecSave); ok {
+		return formSaveAdapter{f}, true
+	}
+	return nil, false
+}
+
+func getFormDelete(spec FormSpec) (FormSpecDeleteContext, bool) {
+	if f, ok := spec.(FormSpecDeleteContext); ok {
+		return f, true
+	}
+	if f, ok := spec.(FormSpecDelete); ok {
+		return formDeleteAdapter{f}, true
+	}
+	return nil, false
+}
+
 // FormItem describes a single form entry, e.g. an input box.
+//
+// Supported input types: "input" (HTML type specified by InputTypeHTML), "textarea", "select",
+// "multiselect", "radio", "checkbox", "switch", "file", "group" and the HTML input types
+// "date", "time", "datetime-local", "month" and "color". The value of a file item is the key of
+// the stored file (see StoreFile). A group item contains repeatable rows of items (see
+// FormItem.Group), row items are posted as "<group>.<row index>.<item>".
 type FormItem struct {
 	ID string
 
@@ -47,6 +139,16 @@ type FormItem struct {
 	Value        string
 	DefaultValue string
 
+	// selected values of a "multiselect" item (Value contains the comma separated list)
+	Values []string
+	// options of "select", "multiselect" and "radio" items
+	Options []FormItemOption
+
+	// items of a row of a "group" item (used for new rows)
+	Group FormItems
+	// rows of a "group" item (Value contains the number of rows)
+	Rows []FormItems
+
 	Constraints *FormItemConstraints
 
 	Class       string
@@ -65,96 +167,414 @@ type FormItem struct {
 	IsHorizontal bool
 	IsHidden     bool
 	HasFocus     bool
+
+	// disabled items are not submitted and not validated - posted values are ignored, the value
+	// read for the entity is kept
+	IsDisabled bool
+
+	// conditional visibility and enablement depending on other items (see FormItemCondition)
+	ShowIf   *FormItemCondition
+	EnableIf *FormItemCondition
+	// options depending on other items (replace Options)
+	DependentOptions *FormItemDependentOptions
+
+	// hidden by ShowIf condition
+	isConcealed bool
+
+	// value read for the entity (before assigning posted values)
+	initial *formItemValue
+}
+
+// formItemValue contains the value(s) of a form item.
+type formItemValue struct {
+	Value  string
+	Values []string
+	Rows   []FormItems
+}
+
+// FormItemOption describes a selectable value of a form item.
+type FormItemOption struct {
+	Value string `json:"value"`
+	Label string `json:"label"`
+}
+
+// FormOptions returns options for the given values (labels are equal to the values).
+func FormOptions(values ...string) []FormItemOption {
+	options := make([]FormItemOption, len(values))
+	for i, v := range values {
+		options[i] = FormItemOption{Value: v, Label: v}
+	}
+	return options
+}
+
+// IsCheckable returns true for checkbox and switch items.
+func (fi FormItem) IsCheckable() bool {
+	return fi.InputType == "checkbox" || fi.InputType == "switch"
+}
+
+// IsChecked returns true if a checkbox or switch item is checked.
+func (fi FormItem) IsChecked() bool {
+	return fi.Value == "true"
+}
+
+// IsSelected checks whether the given option value is selected.
+func (fi FormItem) IsSelected(value string) bool {
+	if fi.InputType == "multiselect" {
+		return contains(fi.Values, value)
+	}
+	return fi.Value == value
 }
 
+// HTMLInputType returns the type attribute of items rendered as HTML input element
+// ("" if the item is not rendered as input element).
+func (fi FormItem) HTMLInputType() string {
+	switch fi.InputType {
+	case "input":
+		return fi.InputTypeHTML
+	case "date", "time", "datetime-local", "month", "color":
+		return fi.InputType
+	}
+	return ""
+}
+
+// FileURL returns the download URL of the current file of a file item ("" if there is no file).
+func (fi FormItem) FileURL() string {
+	if fi.InputType != "file" || fi.Value == "" {
+		return ""
+	}
+	return "/files/" + fi.Value
+}
+
+// AcceptedFileTypes returns the value of the HTML accept attribute of a file item.
+func (fi FormItem) AcceptedFileTypes() string {
+	if fi.Constraints == nil {
+		return ""
+	}
+	return strings.Join(fi.Constraints.FileTypes, ",")
+}
+
+func (fi FormItem) hasOption(value string) bool {
+	for _, o := range fi.Options {
+		if o.Value == value {
+			return true
+		}
+	}
+	return false
+}
+
+// FormItemConstraints describes the validation rules of a form item. Empty values of optional
+// items are valid (only custom validation is executed).
 type FormItemConstraints struct {
 	IsMandatory bool
 	IsNumber    bool
+	IsInteger   bool
 
+	// value formats
+	IsEmail bool
+	IsURL   bool
+	IsDate  bool
+
+	// range of number/integer values
 	MinValue float64
 	MaxValue float64
 
+	// length in characters
 	MinLength int
 	MaxLength int
 
+	// regular expression the whole value must match
 	Regexp string
+
+	// Validate is a custom validation function. It can check other items of the form (all values
+	// are already set). The returned error message is translated and shown as help text.
+	Validate func(value string, items FormItems) error
+
+	// file items: maximum file size in bytes (0: unlimited)
+	MaxFileSize int64
+	// file items: allowed MIME types ("application/pdf"), groups ("image/*") or extensions (".pdf")
+	FileTypes []string
 }
 
-func (fi *FormItem) validate(value string) bool {
+func (fi *FormItem) validate(value string, items FormItems, tr translateFunc) bool {
+	if fi.InputType == "group" {
+		return fi.validateRows(value, items, tr)
+	}
+
+	// check values (independent of constraints) ..
+
+	// .. file: value must be a file key
+	if fi.InputType == "file" && value != "" && !isValidFileKey(value) {
+		return fi.invalid(tr(FormMessageInvalidFile))
+	}
+
+	// .. options: selected values must be valid
+	switch fi.InputType {
+	case "select", "radio", "multiselect":
+		values := fi.Values
+		if fi.InputType != "multiselect" && value != "" {
+			values = []string{value}
+		}
+
+		if len(values) == 0 && fi.Constraints != nil && fi.Constraints.IsMandatory {
+			return fi.invalid(tr(FormMessageRequired))
+		}
+		for _, v := range values {
+			if !fi.hasOption(v) {
+				return fi.invalid(tr(FormMessageInvalidSelection))
+			}
+		}
+		return fi.validateCustom(value, items, tr)
+	}
+
 	if fi.Constraints == nil {
 		return true
 	}
+	c := fi.Constraints
 
 	// check constraints ..
 
+	// .. checkbox or switch: required means checked
+	if fi.IsCheckable() {
+		if c.IsMandatory && value != "true" {
+			return fi.invalid(tr(FormMessageRequired))
+		}
+		return fi.validateCustom(value, items, tr)
+	}
+
 	// .. field required?
-	if len(value) == 0 && fi.Constraints.IsMandatory {
-		fi.Help = "required"
-		fi.HelpClass = "is-danger"
-		return false
+	if len(value) == 0 {
+		if c.IsMandatory {
+			return fi.invalid(tr(FormMessageRequired))
+		}
+		// optional and empty: no further checks
+		return fi.validateCustom(value, items, tr)
 	}
 
-	// .. value length
-	if len(value) < fi.Constraints.MinLength {
-		fi.Help = fmt.Sprintf("too short - at least %d characters", fi.Constraints.MinLength)
-		fi.HelpClass = "is-danger"
-		return false
+	// .. value length (in characters)
+	length := utf8.RuneCountInString(value)
+	if length < c.MinLength {
+		return fi.invalid(tr(FormMessageTooShort, c.MinLength))
 	}
-	if fi.Constraints.MaxLength > 0 && len(value) > fi.Constraints.MaxLength {
-		fi.Help = fmt.Sprintf("too long - at most %d characters", fi.Constraints.MaxLength)
-		fi.HelpClass = "is-danger"
-		return false
+	if c.MaxLength > 0 && length > c.MaxLength {
+		return fi.invalid(tr(FormMessageTooLong, c.MaxLength))
 	}
 
 	// .. number? -> check min/max
-	if fi.Constraints.IsNumber {
-		f, err := strconv.ParseFloat(value, 64)
+	if c.IsNumber || c.IsInteger {
+		var (
+			f   float64
+			err error
+		)
+		if c.IsInteger {
+			var i int64
+			i, err = strconv.ParseInt(value, 10, 64)
+			f = float64(i)
+		} else {
+			f, err = strconv.ParseFloat(value, 64)
+		}
+		if err != nil {
+			if c.IsInteger {
+				return fi.invalid(tr(FormMessageNoInteger))
+			}
+			return fi.invalid(tr(FormMessageNoNumber))
+		}
+
+		if f < c.MinValue {
+			return fi.invalid(tr(FormMessageTooSmall, c.MinValue))
+		}
+		if f > c.MaxValue {
+			return fi.invalid(tr(FormMessageTooBig, c.MaxValue))
+		}
+	}
+
+	// .. formats
+	if c.IsEmail {
+		address, err := mail.ParseAddress(value)
+		if err != nil || address.Address != value {
+			return fi.invalid(tr(FormMessageInvalidEmail))
+		}
+	}
+	if c.IsURL {
+		u, err := url.ParseRequestURI(value)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fi.invalid(tr(FormMessageInvalidURL))
+		}
+	}
+	if c.IsDate {
+		_, err := time.Parse("2006-01-02", value)
 		if err != nil {
-			fi.Help = fmt.Sprint("not a number")
-			fi.HelpClass = "is-danger"
-			return false
+			return fi.invalid(tr(FormMessageInvalidDate))
 		}
+	}
 
-		if f < fi.Constraints.MinValue {
-			fi.Help = fmt.Sprintf("value too small, must be >= %f", fi.Constraints.MinValue)
-			fi.HelpClass = "is-danger"
-			return false
+	// .. regular expression (must match the whole value)
+	if c.Regexp != "" {
+		re, err := compileFormRegexp(c.Regexp)
+		if err != nil {
+			Log.ErrorContext("invalid form item regexp", LogContext{"name": fi.Name, "regexp": c.Regexp, "error": err})
+			return fi.invalid(tr(FormMessageInvalidValue))
 		}
-		if f > fi.Constraints.MaxValue {
-			fi.Help = fmt.Sprintf("value too big, must be <= %f", fi.Constraints.MaxValue)
-			fi.HelpClass = "is-danger"
-			return false
+		if !re.MatchString(value) {
+			return fi.invalid(tr(FormMessageInvalidFormat))
 		}
 	}
 
-	// TODO: check regular expression
+	return fi.validateCustom(value, items, tr)
+}
+
+// Form messages (validation and framework messages). The messages are message IDs translated
+// into the request language using the i18n bundle (PO files). Applications customize the texts
+// by translations - also for English.
+const (
+	FormMessageRequired         = "required"
+	FormMessageInvalidSelection = "invalid selection"
+	FormMessageTooShort         = "too short - at least %d characters"
+	FormMessageTooLong          = "too long - at most %d characters"
+	FormMessageNoNumber         = "not a number"
+	FormMessageNoInteger        = "not an integer"
+	FormMessageTooSmall         = "value too small, must be >= %g"
+	FormMessageTooBig           = "value too big, must be <= %g"
+	FormMessageInvalidEmail     = "not a valid email address"
+	FormMessageInvalidURL       = "not a valid URL"
+	FormMessageInvalidDate      = "not a valid date"
+	FormMessageInvalidFormat    = "invalid format"
+	FormMessageInvalidValue     = "invalid value"
+	FormMessageInvalidValues    = "invalid form values"
+
+	FormMessageInvalidFile     = "invalid file"
+	FormMessageFileTooLarge    = "file too large - at most %d KB"
+	FormMessageFileTypeInvalid = "file type not allowed"
+	FormMessageUploadFailed    = "upload failed"
+
+	FormMessageConflict       = "The entry was changed in the meantime. Current values:"
+	FormMessageConflictReload = "reload"
 
+	FormMessageChooseFile  = "Choose a file ..."
+	FormMessageCurrentFile = "current file"
+)
+
+// validateCustom executes the custom validation function (if defined). The error message is
+// translated (message ID).
+func (fi *FormItem) validateCustom(value string, items FormItems, tr translateFunc) bool {
+	if fi.Constraints == nil || fi.Constraints.Validate == nil {
+		return true
+	}
+
+	err := fi.Constraints.Validate(value, items)
+	if err != nil {
+		return fi.invalid(tr(err.Error()))
+	}
 	return true
 }
 
+// invalid sets the validation message of the item. Always returns false.
+func (fi *FormItem) invalid(message string) bool {
+	fi.Help = message
+	fi.HelpClass = "is-danger"
+	return false
+}
+
+var formRegexps sync.Map
+
+// compileFormRegexp returns the compiled (and cached) regular expression. The expression must
+// match the whole value.
+func compileFormRegexp(expr string) (*regexp.Regexp, error) {
+	if re, ok := formRegexps.Load(expr); ok {
+		return re.(*regexp.Regexp), nil
+	}
+
+	re, err := regexp.Compile("^(?:" + expr + ")$")
+	if err != nil {
+		return nil, err
+	}
+	formRegexps.Store(expr, re)
+	return re, nil
+}
+
 // FormItems is a list of form items.
 type FormItems []FormItem
 
-func (fi *FormItems) setValues(v url.Values) bool {
-	var isValid = true
+// setValues sets the item values from the given (posted) values and validates all items.
+func (fi *FormItems) setValues(v url.Values, tr translateFunc) bool {
+	fi.assignValues(v)
+	return fi.validateItems(tr, nil)
+}
 
+// assignValues sets the item values from the given (posted) values without validation.
+func (fi *FormItems) assignValues(v url.Values) {
 	for i, item := range *fi {
 		// get provided (URL) value
-		value := strings.TrimSpace(v.Get(item.Name))
-		if value == "" {
-			value = item.DefaultValue
+		var value string
+		switch {
+		case item.InputType == "multiselect":
+			// all selected values
+			item.Values = []string{}
+			for _, s := range v[item.Name] {
+				if s = strings.TrimSpace(s); s != "" {
+					item.Values = append(item.Values, s)
+				}
+			}
+			value = strings.Join(item.Values, ",")
+		case item.InputType == "group":
+			item.Rows = item.groupRows(v)
+			value = strconv.Itoa(len(item.Rows))
+		case item.IsCheckable():
+			// unchecked checkboxes are not submitted
+			checked := v.Get(item.Name)
+			value = strconv.FormatBool(checked == "true" || checked == "on")
+		default:
+			value = strings.TrimSpace(v.Get(item.Name))
+			if value == "" {
+				value = item.DefaultValue
+			}
+		}
+
+		// keep read value (restored for disabled items)
+		if item.initial == nil {
+			(*fi)[i].initial = &formItemValue{
+				Value:  (*fi)[i].Value,
+				Values: (*fi)[i].Values,
+				Rows:   (*fi)[i].Rows,
+			}
 		}
 
-		// validate item
-		itemIsValid := item.validate(value)
+		// set value (independent of validity)
+		(*fi)[i].Value = value
+		(*fi)[i].Values = item.Values
+		(*fi)[i].Rows = item.Rows
+	}
+}
 
-		// update item ..
-		// .. set value (independent of validity)
-		item.Value = value
-		// .. set focus on first invalid form item
-		item.HasFocus = isValid && !itemIsValid
+// validateItems validates the items selected by the include function (nil: all items). Values
+// must be set before - custom validation can check other items. Items hidden or disabled by
+// conditions are skipped (posted values are replaced by the read values). Sets the focus on
+// the first invalid item. Returns false if any selected item is invalid.
+func (fi *FormItems) validateItems(tr translateFunc, include func(item FormItem) bool) bool {
+	var isValid = true
 
-		(*fi)[i] = item
+	fi.applyConditions()
+	if fi.resetDisabled() {
+		// conditions can depend on reset items
+		fi.applyConditions()
+	}
+
+	for i := range *fi {
+		item := &(*fi)[i]
+		if include != nil && !include(*item) {
+			continue
+		}
+		if item.HelpClass == "is-danger" {
+			// remove message of previous validation
+			item.Help, item.HelpClass = "", ""
+		}
+		if !item.IsEnabled() {
+			// hidden (by condition) or disabled items are not validated
+			continue
+		}
+		itemIsValid := item.validate(item.Value, *fi, tr)
+
+		// set focus on first invalid form item
+		item.HasFocus = isValid && !itemIsValid
 
 		// update overall validation result
 		isValid = isValid && itemIsValid
@@ -163,6 +583,26 @@ func (fi *FormItems) setValues(v url.Values) bool {
 	return isValid
 }
 
+// resetDisabled restores the read values of hidden or disabled items (posted values must not
+// be saved). Returns true if any value was reset.
+func (fi *FormItems) resetDisabled() bool {
+	isReset := false
+	for i := range *fi {
+		item := &(*fi)[i]
+		if item.IsEnabled() || item.initial == nil {
+			continue
+		}
+
+		if item.Value != item.initial.Value || !reflect.DeepEqual(item.Values, item.initial.Values) {
+			isReset = true
+		}
+		item.Value = item.initial.Value
+		item.Values = item.initial.Values
+		item.Rows = item.initial.Rows
+	}
+	return isReset
+}
+
 func (fi *FormItems) Get(name string) *FormItem {
 	for _, item := range *fi {
 		if item.Name == name {
@@ -190,8 +630,8 @@ func getFormsHandlerFunc(forms []FormSpec) AppRequestHandler {
 	}
 
 	return func(w http.ResponseWriter, r *http.Request) {
-		// determine form
-		formName := getElementName("forms", r.URL.Path)
+		// determine form and operation ('/forms/<name>/<operation>')
+		formName, operation, _ := strings.Cut(getElementName("forms", r.URL.Path), "/")
 		Log.DebugContextR(
 			r, "handle form",
 			LogContext{
@@ -202,7 +642,23 @@ func getFormsHandlerFunc(forms []FormSpec) AppRequestHandler {
 
 		formSpec, ok := nameToSpec[formName]
 		if !ok {
-			RespondNotFound(w)
+			respondFormStatus(w, r, http.StatusNotFound)
+			return
+		}
+
+		addComponentLogFields(r, "form", formName)
+		componentRequest("form", formName)
+		defer startComponentTimer("form", formName, "total").stop()
+
+		r, span := startSpan(r, "form "+formName)
+		defer span.End()
+
+		// JSON API mode (values can be posted as JSON object)
+		isJSON := isJSONRequest(r)
+		err := parseJSONForm(w, r)
+		if err != nil {
+			Log.WarnErrorR(r, "could not parse JSON form data", err)
+			respondFormStatus(w, r, http.StatusBadRequest)
 			return
 		}
 
@@ -213,126 +669,408 @@ func getFormsHandlerFunc(forms []FormSpec) AppRequestHandler {
 			csrf         = r.Form.Get("csrf")
 		)
 
+		switch operation {
+		case "":
+		case "validate":
+			// live validation of a single field
+			handleFormValidation(w, r, formName, formSpec, csrf)
+			return
+		case "row":
+			// new row of a group item
+			handleFormRow(w, r, formName, formSpec, csrf)
+			return
+		case "draft":
+			// autosave/discard draft
+			handleFormDraft(w, r, formName, formSpec, csrf)
+			return
+		default:
+			respondFormStatus(w, r, http.StatusNotFound)
+			return
+		}
+
 		// process request
 		switch r.Method {
 		case http.MethodGet:
 			// does the form support GET method?
 			formRead, ok := formSpec.(FormSpecRead)
 			if !ok {
-				RespondNotImplemented(w)
+				respondFormStatus(w, r, http.StatusNotImplemented)
 				return
 			}
 
-			items, err := formRead.Read(id)
+			items, err := readForm(r, formRead, id)
 			if err != nil {
 				handleFormError(w, r, "could not read/initialize form", err)
 				return
 			}
 
-			renderForm(w, r, formName, items, submitButton, "")
+			if isJSON {
+				respondFormSchema(w, r, formName, id, formSpec, items)
+				return
+			}
+			if wizard, ok := formSpec.(FormSpecWizard); ok {
+				handleWizardRead(w, r, formName, wizard, items, id, submitButton)
+				return
+			}
+
+			draft := newFormDraft(r, formSpec, id)
+			err = restoreFormDraft(r, formName, id, &items, draft)
+			if err != nil {
+				Log.WarnErrorR(r, "could not restore form draft", err)
+			}
+
+			renderForm(w, r, formName, items, submitButton, "", nil, draft)
 		case http.MethodPost:
 			// does the form support POST method?
-			formSave, ok := formSpec.(FormSpecSave)
+			formSave, ok := getFormSave(formSpec)
 			if !ok {
-				RespondNotImplemented(w)
+				respondFormStatus(w, r, http.StatusNotImplemented)
 				return
 			}
 
 			// CSRF protection
 			if !IsCSRFtokenValid(r, csrf) {
 				Log.DebugR(r, "CSRF token mismatch")
-				RespondBadRequest(w)
+				respondFormStatus(w, r, http.StatusBadRequest)
 				return
 			}
 
-			// initialize (empty) form
-			items, err := formSave.Read("")
+			if wizard, ok := formSpec.(FormSpecWizard); ok && !isJSON {
+				handleWizardPost(w, r, formName, wizard, formSave, submitButton)
+				return
+			}
+
+			// initialize form with the current values (kept for disabled items)
+			items, err := readForm(r, formSave, id)
 			if err != nil {
 				handleFormError(w, r, "could not initialize form", err)
 				return
 			}
 
-			// integrate values form posted form data and validate
-			isValid := items.setValues(r.Form)
-			if isValid {
-				action, err := formSave.Save(id, items)
-				if err != nil {
-					Log.ErrorObjR(r, "could not save form item", err)
-					RespondInternalServerError(w)
-					return
-				}
+			// integrate values form posted form data (including uploaded files) and validate
+			items.addVersionItem(r.Form)
+			fileErrors := items.storeFiles(r)
+			isValid := items.setValues(r.Form, contextTranslator(r.Context()))
+			isValid = items.setFileErrors(fileErrors) && isValid
 
-				if action.isFormError {
-					renderForm(w, r, formName, items, submitButton, action.message)
+			render := func(errorMessage string, conflict *formConflict) {
+				if isJSON {
+					respondFormErrors(w, r, items, errorMessage, conflict)
 					return
 				}
-
-				action.doCloseDialog = r.Form.Get("dialog") == "true"
-				action.redirect = r.Form.Get("ref")
-
-				handleResponseAction(w, r, action)
+				renderForm(w, r, formName, items, submitButton, errorMessage, conflict, newFormDraft(r, formSpec, id))
+			}
+			if !isValid {
+				componentEvent("form", formName, "validation_failed")
+				render("", nil)
 				return
 			}
 
-			renderForm(w, r, formName, items, submitButton, "")
+			saveForm(w, r, formName, formSave, id, items, render)
 		case http.MethodDelete:
 			// does the form support DELETE method?
-			formDelete, ok := formSpec.(FormSpecDelete)
+			formDelete, ok := getFormDelete(formSpec)
 			if !ok {
-				RespondNotImplemented(w)
+				respondFormStatus(w, r, http.StatusNotImplemented)
 				return
 			}
 
 			// CSRF protection
 			if !IsCSRFtokenValid(r, csrf) {
 				Log.DebugR(r, "CSRF token mismatch")
-				RespondBadRequest(w)
+				respondFormStatus(w, r, http.StatusBadRequest)
 				return
 			}
 
-			action, err := formDelete.Delete(id)
+			action, err := inRequestTransaction(
+				r, formDelete,
+				func(r *http.Request) (*ResponseAction, error) {
+					return formDelete.DeleteContext(r.Context(), id)
+				},
+			)
 			if err != nil {
+				componentEvent("form", formName, "delete_error")
 				handleFormError(w, r, "could not delete form item", err)
 				return
 			}
+			componentEvent("form", formName, "delete")
+			auditLog(r, AuditRecord{Action: "delete", Target: "form:" + formName, TargetID: id})
 
 			action.doCloseDialog = r.Form.Get("dialog") == "true"
 			handleResponseAction(w, r, action)
 		default:
-			RespondNotImplemented(w)
+			respondFormStatus(w, r, http.StatusNotImplemented)
 		}
 	}
 }
 
+// saveForm saves the (valid) form items - including version check, audit log and response
+// action. The render function renders the form with an error message (or version conflict).
+func saveForm(
+	w http.ResponseWriter, r *http.Request,
+	formName string, formSave FormSpecSaveContext, id string, items FormItems,
+	render func(errorMessage string, conflict *formConflict),
+) {
+	// current values (for audit log and version check)
+	var before map[string]string
+	if id != "" && (Config.Audit.Enabled || items.Get(FormVersionItem) != nil) {
+		current, err := readForm(r, formSave, id)
+		if err != nil {
+			handleFormError(w, r, "could not read form", err)
+			return
+		}
+		before = current.auditValues()
+
+		if conflict := items.versionConflict(id, current); conflict != nil {
+			componentEvent("form", formName, "conflict")
+			render(TR(r, FormMessageConflict), conflict)
+			return
+		}
+	}
+
+	saveTimer := startComponentTimer("form", formName, "save")
+	action, err := inRequestTransaction(
+		r, formSave,
+		func(r *http.Request) (*ResponseAction, error) {
+			return formSave.SaveContext(r.Context(), id, items)
+		},
+	)
+	saveTimer.stop()
+	if errors.Is(err, ErrorVersionConflict) {
+		componentEvent("form", formName, "conflict")
+		handleFormConflict(w, r, formSave, id, items, render)
+		return
+	}
+	if err != nil {
+		componentEvent("form", formName, "save_error")
+		Log.ErrorObjR(r, "could not save form item", err)
+		respondFormStatus(w, r, http.StatusInternalServerError)
+		return
+	}
+
+	if action.isFormError {
+		componentEvent("form", formName, "form_error")
+		render(action.message, nil)
+		return
+	}
+	componentEvent("form", formName, "save")
+
+	items.attachFiles(r)
+
+	if _, ok := getFormDraft(formSave); ok {
+		err = deleteFormDraft(r, formName, id)
+		if err != nil {
+			Log.WarnErrorR(r, "could not delete form draft", err)
+		}
+	}
+
+	auditAction, auditID := "update", id
+	if id == "" {
+		auditAction, auditID = "create", action.id
+	}
+	auditLog(r, AuditRecord{
+		Action:   auditAction,
+		Target:   "form:" + formName,
+		TargetID: auditID,
+		Before:   before,
+		After:    items.auditValues(),
+	})
+
+	action.doCloseDialog = r.Form.Get("dialog") == "true"
+	action.redirect = r.Form.Get("ref")
+
+	handleResponseAction(w, r, action)
+}
+
 func handleFormError(w http.ResponseWriter, r *http.Request, message string, err error) {
 	switch err {
 	case ErrorFormItemNotFound:
-		RespondNotFound(w)
+		respondFormStatus(w, r, http.StatusNotFound)
 		return
 	case ErrorFormInvalidRequest:
-		RespondBadRequest(w)
+		respondFormStatus(w, r, http.StatusBadRequest)
 		return
 	}
 
 	// all other cases: log error and respond
 	Log.ErrorObjR(r, message, err)
-	RespondInternalServerError(w)
+	respondFormStatus(w, r, http.StatusInternalServerError)
+}
+
+// handleFormConflict renders the form including the current values of a concurrently changed record.
+func handleFormConflict(
+	w http.ResponseWriter, r *http.Request,
+	formSave FormSpecSaveContext, id string, items FormItems,
+	render func(errorMessage string, conflict *formConflict),
+) {
+	current, err := readForm(r, formSave, id)
+	if err != nil {
+		handleFormError(w, r, "could not read form", err)
+		return
+	}
+
+	render(TR(r, FormMessageConflict), items.conflict(id, current))
+}
+
+// handleFormValidation validates the posted form values and renders the field specified by the
+// URL parameter "field" (including its validation message). All values of the form are posted,
+// so custom validation functions can check other items. The items are read for the edited
+// entity (like on save).
+func handleFormValidation(w http.ResponseWriter, r *http.Request, name string, formSpec FormSpec, csrf string) {
+	formRead, ok := formSpec.(FormSpecRead)
+	if !ok || r.Method != http.MethodPost {
+		RespondNotImplemented(w)
+		return
+	}
+
+	// CSRF protection
+	if !IsCSRFtokenValid(r, csrf) {
+		Log.DebugR(r, "CSRF token mismatch")
+		RespondBadRequest(w)
+		return
+	}
+
+	// wizard: values of all steps
+	id, values := r.Form.Get("id"), r.Form
+	if _, ok := formSpec.(FormSpecWizard); ok {
+		id, values = wizardFormValues(r, name)
+	}
+
+	items, err := readForm(r, formRead, id)
+	if err != nil {
+		handleFormError(w, r, "could not read form", err)
+		return
+	}
+
+	items.setValues(values, contextTranslator(r.Context()))
+	item := items.Get(r.URL.Query().Get("field"))
+	if item == nil {
+		RespondNotFound(w)
+		return
+	}
+	componentEvent("form", name, "validate")
+
+	// keep focus (on next field)
+	item.HasFocus = false
+
+	submitButton := r.URL.Query().Get("btn")
+	tr := contextTranslator(r.Context())
+	err = renderInternalTemplate(w, r, "form_field", newFormField(name, submitButton, *item, items, tr))
+
+	// update dependent fields (out of band, without validation messages)
+	for _, dependent := range items.dependents(item.Name) {
+		if err != nil {
+			break
+		}
+
+		dependent.HasFocus = false
+		if dependent.HelpClass == "is-danger" {
+			dependent.Help, dependent.HelpClass = "", ""
+		}
+
+		field := newFormField(name, submitButton, dependent, items, tr)
+		field.IsOutOfBand = true
+		err = renderInternalTemplate(w, r, "form_field", field)
+	}
+
+	if err != nil {
+		componentEvent("form", name, "render_error")
+		Log.ErrorContextR(
+			r, "could not render form field",
+			LogContext{"name": name, "field": item.Name, "error": err},
+		)
+		RespondInternalServerError(w)
+	}
+}
+
+// formField is the template context of a single form item.
+type formField struct {
+	FormItem
+
+	FormID     string
+	FormButton string
+
+	// live validation of the field value and update of dependent fields (on change)
+	Validate bool
+	// field replaces the rendered field (HTMX out of band swap)
+	IsOutOfBand bool
+
+	// rows of a group item
+	Rows []formRow
+
+	// file items: texts of the file selection and the link to the current file
+	ChooseFileText  string
+	CurrentFileText string
 }
 
-func renderForm(w http.ResponseWriter, r *http.Request, name string, form FormItems, submitButton, errorMessage string) {
-	// initialize form context
-	context := struct {
-		ID     string
-		Items  FormItems
-		Button string
-		Error  string
-	}{name, form, submitButton, errorMessage}
+func newFormField(form, submitButton string, item FormItem, items FormItems, tr translateFunc) formField {
+	field := formField{
+		FormItem:   item,
+		FormID:     form,
+		FormButton: submitButton,
+		Validate: !item.IsHidden && item.InputType != "file" && item.InputType != "group" &&
+			(item.Constraints != nil || len(items.dependents(item.Name)) > 0),
+	}
+
+	if item.InputType == "file" {
+		field.ChooseFileText = tr(FormMessageChooseFile)
+		field.CurrentFileText = tr(FormMessageCurrentFile)
+	}
+
+	for i, row := range item.Rows {
+		field.Rows = append(field.Rows, newFormRow(form, submitButton, item.Name, i, row, tr))
+	}
+
+	return field
+}
+
+// formView is the template context of a form.
+type formView struct {
+	ID       string
+	Items    FormItems
+	Fields   []formField
+	Button   string
+	Error    string
+	Conflict *formConflict
+	Wizard   *formWizard
+	Draft    *formDraft
+}
+
+func renderForm(
+	w http.ResponseWriter, r *http.Request,
+	name string, form FormItems, submitButton, errorMessage string, conflict *formConflict,
+	draft *formDraft,
+) {
+	form.applyConditions()
+
+	tr := contextTranslator(r.Context())
+	fields := make([]formField, len(form))
+	for i, item := range form {
+		fields[i] = newFormField(name, submitButton, item, form, tr)
+	}
+
+	renderFormView(w, r, formView{
+		ID:       name,
+		Items:    form,
+		Fields:   fields,
+		Button:   submitButton,
+		Error:    errorMessage,
+		Conflict: conflict,
+		Draft:    draft,
+	})
+}
+
+func renderFormView(w http.ResponseWriter, r *http.Request, view formView) {
+	if view.Conflict != nil {
+		view.Conflict.ReloadText = TR(r, FormMessageConflictReload)
+	}
 
-	err := renderInternalTemplate(w, r, "form", context)
+	err := renderInternalTemplate(w, r, "form", view)
 	if err != nil {
+		componentEvent("form", view.ID, "render_error")
 		Log.ErrorContextR(
 			r, "could not render form",
-			LogContext{"name": name, "error": err},
+			LogContext{"name": view.ID, "error": err},
 		)
 		RespondInternalServerError(w)
 	}
@@ -0,0 +1,116 @@
+package uos
+
+// FormItemCondition describes a condition on the value of another form item, e.g. to show an
+// item only if a checkbox is checked:
+//
+//	FormItem{Name: "company", ShowIf: &FormItemCondition{Item: "is_business"}}
+//
+// Items hidden by their ShowIf condition are not submitted (disabled) and not validated.
+// Changes of the referenced item re-render the dependent items (HTMX).
+type FormItemCondition struct {
+	// name of the referenced item
+	Item string `json:"item"`
+	// values meeting the condition - if empty, any value except "" and "false" (unchecked
+	// checkbox) meets the condition
+	Values []string `json:"values,omitempty"`
+	// negate the condition
+	Not bool `json:"not,omitempty"`
+}
+
+// isMet checks the condition. Items hidden by their own condition have no value.
+func (c FormItemCondition) isMet(items FormItems) bool {
+	isMet := false
+	if item := items.Get(c.Item); item != nil && item.IsVisible() {
+		switch {
+		case len(c.Values) == 0:
+			isMet = item.Value != "" && item.Value != "false"
+		case item.InputType == "multiselect":
+			for _, v := range item.Values {
+				isMet = isMet || contains(c.Values, v)
+			}
+		default:
+			isMet = contains(c.Values, item.Value)
+		}
+	}
+
+	return isMet != c.Not
+}
+
+// FormItemDependentOptions determines the options of an item based on the values of other
+// items, e.g. the states of the selected country. Changes of the referenced items re-render
+// the item (HTMX).
+type FormItemDependentOptions struct {
+	// names of the items the options depend on
+	Items []string
+	// Options returns the options for the current item values.
+	Options func(items FormItems) []FormItemOption
+}
+
+// IsVisible returns false if the item is hidden by its ShowIf condition.
+func (fi FormItem) IsVisible() bool {
+	return !fi.isConcealed
+}
+
+// IsEnabled returns false if the item is disabled or hidden by its ShowIf condition.
+func (fi FormItem) IsEnabled() bool {
+	return !fi.IsDisabled && !fi.isConcealed
+}
+
+// dependsOn checks whether the item is affected by changes of the specified item.
+func (fi FormItem) dependsOn(name string) bool {
+	if fi.ShowIf != nil && fi.ShowIf.Item == name {
+		return true
+	}
+	if fi.EnableIf != nil && fi.EnableIf.Item == name {
+		return true
+	}
+	return fi.DependentOptions != nil && contains(fi.DependentOptions.Items, name)
+}
+
+// applyConditions evaluates the visibility and enablement conditions and determines the
+// dependent options of all items (in order). Must be called after the values are set.
+func (fi FormItems) applyConditions() {
+	for i := range fi {
+		item := &fi[i]
+
+		if item.DependentOptions != nil && item.DependentOptions.Options != nil {
+			item.Options = item.DependentOptions.Options(fi)
+		}
+		if item.ShowIf != nil {
+			item.isConcealed = !item.ShowIf.isMet(fi)
+		}
+		if item.EnableIf != nil {
+			item.IsDisabled = !item.EnableIf.isMet(fi)
+		}
+	}
+}
+
+// dependents returns all items affected by changes of the specified item (including
+// indirectly affected items).
+func (fi FormItems) dependents(name string) []FormItem {
+	result := []FormItem{}
+	names := []string{name}
+	for len(names) > 0 {
+		current := names[0]
+		names = names[1:]
+
+		for _, item := range fi {
+			if item.Name == name || !item.dependsOn(current) || containsItem(result, item.Name) {
+				continue
+			}
+			result = append(result, item)
+			names = append(names, item.Name)
+		}
+	}
+
+	return result
+}
+
+func containsItem(items []FormItem, name string) bool {
+	for _, item := range items {
+		if item.Name == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,315 @@
+package uos
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"reflect"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// FormSpecDraft can be implemented by forms to keep unsaved changes, e.g. of long texts. The
+// form values are saved periodically as draft of the authenticated user (server-side) and
+// restored the next time the form is read for the same entity. The draft is removed if the
+// form is saved successfully or the user discards it. Drafts expire after 30 days. Uploaded
+// files and passwords are not part of drafts. Drafts are not supported for wizards and in JSON
+// API mode.
+type FormSpecDraft interface {
+	// DraftInterval returns the autosave interval (0: default interval of 30 seconds).
+	DraftInterval() time.Duration
+}
+
+// default autosave interval
+const formDraftDefaultInterval = 30 * time.Second
+
+// drafts not changed within this duration are removed
+const formDraftMaxAge = 30 * 24 * time.Hour
+
+var formDraftCleanup = struct {
+	stop chan struct{}
+}{}
+
+// Draft message IDs (see form messages).
+const (
+	FormMessageDraftRestored = "Unsaved changes restored."
+	FormMessageDraftDiscard  = "Discard"
+)
+
+// FormDraft contains the unsaved values of a form.
+type FormDraft struct {
+	gorm.Model
+
+	// owner of the draft
+	UserID uint `gorm:"uniqueIndex:idx_form_draft"`
+
+	// form and ID of the edited entity ("": new entity)
+	Form     string `gorm:"uniqueIndex:idx_form_draft;size:255"`
+	TargetID string `gorm:"uniqueIndex:idx_form_draft;size:255"`
+
+	// JSON representation of the form values
+	Values string
+}
+
+func (FormDraft) TableName() string {
+	return "internal_form_drafts"
+}
+
+// formDraft is the template context of a form with drafts.
+type formDraft struct {
+	// ID of the edited entity
+	ID string
+	// autosave interval (seconds)
+	Interval int
+
+	// the form shows the values of a draft
+	IsRestored bool
+
+	RestoredText string
+	DiscardText  string
+}
+
+// getFormDraft returns the draft specification of the form (if drafts are supported).
+func getFormDraft(spec interface{}) (FormSpecDraft, bool) {
+	if adapter, ok := spec.(formSaveAdapter); ok {
+		spec = adapter.FormSpecSave
+	}
+	if _, ok := spec.(FormSpecWizard); ok {
+		return nil, false
+	}
+
+	f, ok := spec.(FormSpecDraft)
+	return f, ok
+}
+
+// formDraftUser returns the ID of the authenticated user (0: anonymous - no drafts).
+func formDraftUser(r *http.Request) uint {
+	if user, ok := ContextUser(r.Context()); ok {
+		return user.ID
+	}
+	return 0
+}
+
+// newFormDraft returns the draft template context or nil if drafts are not supported.
+func newFormDraft(r *http.Request, spec FormSpec, id string) *formDraft {
+	formDraftSpec, ok := getFormDraft(spec)
+	if !ok || formDraftUser(r) == 0 {
+		return nil
+	}
+
+	interval := formDraftSpec.DraftInterval()
+	if interval <= 0 {
+		interval = formDraftDefaultInterval
+	}
+	if interval < time.Second {
+		interval = time.Second
+	}
+
+	return &formDraft{
+		ID:           id,
+		Interval:     int(interval / time.Second),
+		RestoredText: TR(r, FormMessageDraftRestored),
+		DiscardText:  TR(r, FormMessageDraftDiscard),
+	}
+}
+
+// draftValues returns the item values stored in drafts (without uploaded files and passwords).
+func (fi FormItems) draftValues() url.Values {
+	values := fi.values()
+	for _, item := range fi {
+		if item.InputType == "file" || item.InputTypeHTML == "password" {
+			delete(values, item.Name)
+		}
+	}
+	return values
+}
+
+// formDraftCondition selects the draft of the specified user and entity (including new
+// entities with an empty ID).
+func formDraftCondition(userID uint, name, id string) map[string]interface{} {
+	return map[string]interface{}{"user_id": userID, "form": name, "target_id": id}
+}
+
+// restoreFormDraft assigns the values of the user's draft (if any) to the items read for the
+// specified entity. Uploaded files and passwords of the entity are kept. Expired drafts are
+// ignored.
+func restoreFormDraft(r *http.Request, name, id string, items *FormItems, draft *formDraft) error {
+	if draft == nil {
+		return nil
+	}
+
+	var stored FormDraft
+	err := DBContext(r).
+		Where(formDraftCondition(formDraftUser(r), name, id)).
+		Where("updated_at >= ?", time.Now().Add(-formDraftMaxAge)).
+		Limit(1).Find(&stored).Error
+	if err != nil || stored.ID == 0 {
+		return err
+	}
+
+	values := url.Values{}
+	err = json.Unmarshal([]byte(stored.Values), &values)
+	if err != nil {
+		return err
+	}
+	for _, item := range *items {
+		if item.InputType == "file" || item.InputTypeHTML == "password" {
+			values.Set(item.Name, item.Value)
+		}
+	}
+
+	items.addVersionItem(values)
+	items.assignValues(values)
+	draft.IsRestored = true
+
+	return nil
+}
+
+// deleteFormDraft removes the user's draft of the specified entity.
+func deleteFormDraft(r *http.Request, name, id string) error {
+	userID := formDraftUser(r)
+	if userID == 0 {
+		return nil
+	}
+
+	return DBContext(r).Unscoped().
+		Where(formDraftCondition(userID, name, id)).
+		Delete(&FormDraft{}).Error
+}
+
+// handleFormDraft saves the posted values as draft (POST) or discards the draft and renders
+// the form with the stored values (DELETE). Drafts equal to the stored values are removed.
+func handleFormDraft(w http.ResponseWriter, r *http.Request, name string, formSpec FormSpec, csrf string) {
+	formRead, ok := formSpec.(FormSpecRead)
+	draft := newFormDraft(r, formSpec, r.Form.Get("id"))
+	if !ok || draft == nil {
+		RespondNotImplemented(w)
+		return
+	}
+
+	// CSRF protection
+	if !IsCSRFtokenValid(r, csrf) {
+		Log.DebugR(r, "CSRF token mismatch")
+		RespondBadRequest(w)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPost:
+		current, err := readForm(r, formRead, draft.ID)
+		if err != nil {
+			handleFormError(w, r, "could not read form", err)
+			return
+		}
+
+		items, err := readForm(r, formRead, draft.ID)
+		if err != nil {
+			handleFormError(w, r, "could not read form", err)
+			return
+		}
+		items.addVersionItem(r.Form)
+		items.assignValues(r.Form)
+
+		// compare with stored values (normalized like posted values)
+		current.assignValues(current.values())
+
+		values := items.draftValues()
+		if reflect.DeepEqual(values, current.draftValues()) {
+			// no changes
+			err = deleteFormDraft(r, name, draft.ID)
+		} else {
+			err = saveFormDraft(r, name, draft.ID, values)
+		}
+		if err != nil {
+			Log.ErrorObjR(r, "could not save form draft", err)
+			RespondInternalServerError(w)
+			return
+		}
+
+		componentEvent("form", name, "draft")
+		w.WriteHeader(http.StatusNoContent)
+	case http.MethodDelete:
+		err := deleteFormDraft(r, name, draft.ID)
+		if err != nil {
+			Log.ErrorObjR(r, "could not delete form draft", err)
+			RespondInternalServerError(w)
+			return
+		}
+		componentEvent("form", name, "draft_discard")
+
+		items, err := readForm(r, formRead, draft.ID)
+		if err != nil {
+			handleFormError(w, r, "could not read form", err)
+			return
+		}
+		renderForm(w, r, name, items, r.Form.Get("btn"), "", nil, draft)
+	default:
+		RespondNotImplemented(w)
+	}
+}
+
+// saveFormDraft creates or updates the user's draft of the specified entity.
+func saveFormDraft(r *http.Request, name, id string, values url.Values) error {
+	data, err := json.Marshal(values)
+	if err != nil {
+		return err
+	}
+
+	draft := FormDraft{
+		UserID:   formDraftUser(r),
+		Form:     name,
+		TargetID: id,
+		Values:   string(data),
+	}
+	return DBContext(r).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}, {Name: "form"}, {Name: "target_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"values", "updated_at"}),
+	}).Create(&draft).Error
+}
+
+func setupFormDrafts() {
+	formDraftCleanup.stop = make(chan struct{})
+	go runFormDraftCleanup(time.Hour, formDraftCleanup.stop)
+}
+
+func cleanupFormDrafts() {
+	if formDraftCleanup.stop != nil {
+		close(formDraftCleanup.stop)
+		formDraftCleanup.stop = nil
+	}
+}
+
+func runFormDraftCleanup(interval time.Duration, stop chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			err := deleteExpiredFormDrafts(context.Background(), time.Now().Add(-formDraftMaxAge))
+			if err != nil {
+				Log.ErrorObj("could not remove expired form drafts", err)
+			}
+		case <-stop:
+			return
+		}
+	}
+}
+
+// deleteExpiredFormDrafts removes drafts not changed since the given time.
+func deleteExpiredFormDrafts(ctx context.Context, before time.Time) error {
+	result := DBFromContext(ctx).Unscoped().
+		Where("updated_at < ?", before).
+		Delete(&FormDraft{})
+	if result.Error != nil {
+		return result.Error
+	}
+
+	if result.RowsAffected > 0 {
+		Log.DebugContext("removed expired form drafts", LogContext{"count": result.RowsAffected})
+	}
+	return nil
+}
@@ -0,0 +1,192 @@
+package uos
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Group items contain repeatable rows of items, e.g. the line items of an invoice:
+//
+//	FormItem{
+//		InputType: "group", Name: "lines", Label: "Lines",
+//		Group: FormItems{
+//			{InputType: "input", InputTypeHTML: "text", Name: "article", Label: "Article"},
+//			{InputType: "input", InputTypeHTML: "number", Name: "amount", Label: "Amount"},
+//		},
+//		Rows: existingLines,
+//	}
+//
+// Rows are added (HTMX) and removed in the browser. Each row is validated separately - custom
+// validation functions of row items get the items of the row. A mandatory group item requires
+// at least one row. Row items are posted as "<group>.<row index>.<item>", row indexes can have
+// gaps (removed rows). Uploads (file items) are not supported in rows.
+
+// groupRows returns the posted rows of the group item (ordered by row index).
+func (fi FormItem) groupRows(v url.Values) []FormItems {
+	prefix := fi.Name + "."
+
+	rowValues := map[int]url.Values{}
+	for key, values := range v {
+		if !strings.HasPrefix(key, prefix) {
+			continue
+		}
+
+		// "<group>.<index>" (row marker) or "<group>.<index>.<item>"
+		index, name, _ := strings.Cut(key[len(prefix):], ".")
+		i, err := strconv.Atoi(index)
+		if err != nil || i < 0 {
+			continue
+		}
+
+		if _, ok := rowValues[i]; !ok {
+			rowValues[i] = url.Values{}
+		}
+		if name != "" {
+			rowValues[i][name] = values
+		}
+	}
+
+	indexes := make([]int, 0, len(rowValues))
+	for i := range rowValues {
+		indexes = append(indexes, i)
+	}
+	sort.Ints(indexes)
+
+	rows := make([]FormItems, len(indexes))
+	for pos, i := range indexes {
+		rows[pos] = fi.newRow()
+		rows[pos].assignValues(rowValues[i])
+	}
+
+	return rows
+}
+
+// nextRowIndex returns an unused row index for the posted values.
+func (fi FormItem) nextRowIndex(v url.Values) int {
+	next := 0
+	prefix := fi.Name + "."
+	for key := range v {
+		if !strings.HasPrefix(key, prefix) {
+			continue
+		}
+
+		index, _, _ := strings.Cut(key[len(prefix):], ".")
+		if i, err := strconv.Atoi(index); err == nil && i >= next {
+			next = i + 1
+		}
+	}
+
+	return next
+}
+
+// newRow returns a copy of the row items of the group item.
+func (fi FormItem) newRow() FormItems {
+	return append(FormItems{}, fi.Group...)
+}
+
+// validateRows validates all rows of the group item.
+func (fi *FormItem) validateRows(value string, items FormItems, tr translateFunc) bool {
+	isValid := true
+	for i := range fi.Rows {
+		isValid = fi.Rows[i].validateItems(tr, nil) && isValid
+	}
+	if !isValid || fi.Constraints == nil {
+		return isValid
+	}
+
+	if fi.Constraints.IsMandatory && len(fi.Rows) == 0 {
+		return fi.invalid(tr(FormMessageRequired))
+	}
+	return fi.validateCustom(value, items, tr)
+}
+
+// rowValues returns the values of all rows as posted by the form.
+func (fi FormItem) rowValues() url.Values {
+	values := url.Values{}
+	for i, row := range fi.Rows {
+		prefix := fmt.Sprintf("%s.%d", fi.Name, i)
+		values.Set(prefix, "")
+		for name, v := range row.values() {
+			values[prefix+"."+name] = v
+		}
+	}
+	return values
+}
+
+// formRow is the template context of a row of a group item.
+type formRow struct {
+	FormID string
+	Group  string
+	Index  int
+
+	Fields []formField
+}
+
+func newFormRow(form, submitButton, group string, index int, row FormItems, tr translateFunc) formRow {
+	row.applyConditions()
+
+	result := formRow{FormID: form, Group: group, Index: index}
+	for _, item := range row {
+		field := newFormField(form, submitButton, item, row, tr)
+		field.Name = fmt.Sprintf("%s.%d.%s", group, index, item.Name)
+		field.Validate = false
+
+		result.Fields = append(result.Fields, field)
+	}
+
+	return result
+}
+
+// handleFormRow renders a new row of the group item specified by the URL parameter "group".
+// The row index is determined from the posted form values. The items are read for the edited
+// entity (like on save).
+func handleFormRow(w http.ResponseWriter, r *http.Request, name string, formSpec FormSpec, csrf string) {
+	formRead, ok := formSpec.(FormSpecRead)
+	if !ok || r.Method != http.MethodPost {
+		RespondNotImplemented(w)
+		return
+	}
+
+	// CSRF protection
+	if !IsCSRFtokenValid(r, csrf) {
+		Log.DebugR(r, "CSRF token mismatch")
+		RespondBadRequest(w)
+		return
+	}
+
+	id := r.Form.Get("id")
+	if _, ok := formSpec.(FormSpecWizard); ok {
+		id, _ = wizardFormValues(r, name)
+	}
+
+	items, err := readForm(r, formRead, id)
+	if err != nil {
+		handleFormError(w, r, "could not read form", err)
+		return
+	}
+
+	group := items.Get(r.URL.Query().Get("group"))
+	if group == nil || group.InputType != "group" {
+		RespondNotFound(w)
+		return
+	}
+	componentEvent("form", name, "add_row")
+
+	row := newFormRow(
+		name, r.URL.Query().Get("btn"), group.Name, group.nextRowIndex(r.Form), group.newRow(),
+		contextTranslator(r.Context()),
+	)
+	err = renderInternalTemplate(w, r, "form_group_row", row)
+	if err != nil {
+		componentEvent("form", name, "render_error")
+		Log.ErrorContextR(
+			r, "could not render form row",
+			LogContext{"name": name, "group": group.Name, "error": err},
+		)
+		RespondInternalServerError(w)
+	}
+}
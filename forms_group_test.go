@@ -0,0 +1,180 @@
+package uos
+
+import (
+	"errors"
+	"net/url"
+	"reflect"
+	"testing"
+
+	"gorm.io/gorm"
+)
+
+func testGroupItem() FormItem {
+	return FormItem{
+		InputType: "group",
+		Name:      "lines",
+		Group: FormItems{
+			{InputType: "input", InputTypeHTML: "text", Name: "article"},
+			{InputType: "input", InputTypeHTML: "number", Name: "amount"},
+		},
+	}
+}
+
+func TestFormGroupRows(t *testing.T) {
+	tests := []struct {
+		name     string
+		values   url.Values
+		wantRows [][]string
+		wantNext int
+	}{
+		{"no rows", url.Values{"other": {"x"}}, [][]string{}, 0},
+		{
+			"rows ordered by index",
+			url.Values{
+				"lines.10.article": {"c"}, "lines.2.article": {"b"}, "lines.0.article": {"a"},
+				"lines.0.amount": {"1"},
+			},
+			[][]string{{"a", "1"}, {"b", ""}, {"c", ""}},
+			11,
+		},
+		{"row marker only", url.Values{"lines.0": {""}}, [][]string{{"", ""}}, 1},
+		{
+			"invalid indexes ignored",
+			url.Values{"lines.x.article": {"x"}, "lines.-1.article": {"y"}, "lines.1.article": {"a"}},
+			[][]string{{"a", ""}},
+			2,
+		},
+		{"other group", url.Values{"lines2.0.article": {"x"}, "lines": {"y"}}, [][]string{}, 0},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			group := testGroupItem()
+
+			rows := group.groupRows(tc.values)
+			got := [][]string{}
+			for _, row := range rows {
+				got = append(got, []string{row.Get("article").Value, row.Get("amount").Value})
+			}
+			if !reflect.DeepEqual(got, tc.wantRows) {
+				t.Errorf("got rows %v, want %v", got, tc.wantRows)
+			}
+
+			if next := group.nextRowIndex(tc.values); next != tc.wantNext {
+				t.Errorf("got next row index %d, want %d", next, tc.wantNext)
+			}
+		})
+	}
+}
+
+func TestFormGroupRowValues(t *testing.T) {
+	items := FormItems{testGroupItem()}
+	items.assignValues(url.Values{
+		"lines.3.article": {"a"}, "lines.3.amount": {"1"},
+		"lines.7.article": {"b"}, "lines.7.amount": {"2"},
+	})
+
+	group := items.Get("lines")
+	if group.Value != "2" || len(group.Rows) != 2 {
+		t.Fatalf("got value %q and %d rows, want 2 rows", group.Value, len(group.Rows))
+	}
+
+	// rows are renumbered without gaps
+	want := url.Values{
+		"lines.0": {""}, "lines.0.article": {"a"}, "lines.0.amount": {"1"},
+		"lines.1": {""}, "lines.1.article": {"b"}, "lines.1.amount": {"2"},
+	}
+	if values := items.values(); !reflect.DeepEqual(values, want) {
+		t.Errorf("got values %v, want %v", values, want)
+	}
+}
+
+type testInvoiceLine struct {
+	gorm.Model
+
+	Article string `uos:"required"`
+	Amount  int
+}
+
+type testInvoice struct {
+	Title string
+	Lines []testInvoiceLine `uos:"group"`
+}
+
+func TestBindFormGroup(t *testing.T) {
+	current := func() testInvoice {
+		return testInvoice{
+			Title: "invoice",
+			Lines: []testInvoiceLine{
+				{Model: gorm.Model{ID: 1}, Article: "a", Amount: 1},
+				{Model: gorm.Model{ID: 2}, Article: "b", Amount: 2},
+			},
+		}
+	}
+
+	tests := []struct {
+		name       string
+		values     url.Values
+		wantLines  []testInvoiceLine
+		wantErrors []string
+	}{
+		{
+			"update, remove and add rows",
+			url.Values{
+				"lines.0.id": {"2"}, "lines.0.article": {"b2"}, "lines.0.amount": {"20"},
+				"lines.5.id": {""}, "lines.5.article": {"c"}, "lines.5.amount": {"3"},
+			},
+			[]testInvoiceLine{
+				{Model: gorm.Model{ID: 2}, Article: "b2", Amount: 20},
+				{Article: "c", Amount: 3},
+			},
+			nil,
+		},
+		{"remove all rows", url.Values{}, []testInvoiceLine{}, nil},
+		{
+			"unknown row ID",
+			url.Values{"lines.0.id": {"99"}, "lines.0.article": {"x"}},
+			nil,
+			[]string{"lines.0.id"},
+		},
+		{
+			"invalid row value",
+			url.Values{"lines.0.id": {"1"}, "lines.0.article": {"a"}, "lines.0.amount": {"many"}},
+			nil,
+			[]string{"lines.0.amount"},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			invoice := current()
+			items := FormFromStruct(invoice)
+			tc.values.Set("title", "invoice")
+			items.assignValues(tc.values)
+
+			err := BindForm(items, &invoice)
+
+			var bindErrors FormBindErrors
+			if tc.wantErrors != nil {
+				if !errors.As(err, &bindErrors) {
+					t.Fatalf("got error %v, want bind errors", err)
+				}
+				names := []string{}
+				for _, e := range bindErrors {
+					names = append(names, e.Item)
+				}
+				if !reflect.DeepEqual(names, tc.wantErrors) {
+					t.Errorf("got errors for %v, want %v", names, tc.wantErrors)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(invoice.Lines, tc.wantLines) {
+				t.Errorf("got lines %+v, want %+v", invoice.Lines, tc.wantLines)
+			}
+		})
+	}
+}
@@ -0,0 +1,339 @@
+package uos
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Forms support a JSON API mode (e.g. for mobile clients) using the same form specifications:
+// if the request accepts "application/json" (and not "text/html"), GET returns the form schema
+// (items, types, constraints, current values), POST returns validation errors (status 422),
+// version conflicts (status 409) or the result of the response action and DELETE returns the
+// result. Other errors (e.g. status 400, 404 or 500) are returned as JSON object with an
+// "error" message. POST requests can send the values as JSON object - arrays of objects are
+// rows of group items. Wizards are saved with a single POST request in JSON mode.
+
+// isJSONRequest checks whether the client requests a JSON response.
+func isJSONRequest(r *http.Request) bool {
+	isJSON := false
+	for _, accepted := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(accepted))
+		if err != nil {
+			continue
+		}
+
+		switch mediaType {
+		case "application/json":
+			isJSON = true
+		case "text/html":
+			return false
+		}
+	}
+
+	return isJSON
+}
+
+// parseJSONForm adds the values of a JSON request body to the (posted) form values. Nested
+// objects and arrays of objects are flattened: {"lines": [{"amount": 1}]} is posted as
+// "lines.0" and "lines.0.amount".
+func parseJSONForm(w http.ResponseWriter, r *http.Request) error {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType != "application/json" {
+		return nil
+	}
+
+	// numbers are kept as sent (no float conversion of large integers)
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, Config.Files.maxRequestSize()))
+	decoder.UseNumber()
+
+	var body map[string]interface{}
+	err := decoder.Decode(&body)
+	if errors.Is(err, io.EOF) {
+		// empty body
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	for key, value := range body {
+		addJSONFormValue(r, key, value)
+	}
+	return nil
+}
+
+func addJSONFormValue(r *http.Request, key string, value interface{}) {
+	add := func(s string) {
+		r.Form.Add(key, s)
+		r.PostForm.Add(key, s)
+	}
+
+	switch v := value.(type) {
+	case nil:
+	case string:
+		add(v)
+	case bool:
+		add(strconv.FormatBool(v))
+	case json.Number:
+		add(v.String())
+	case map[string]interface{}:
+		for k, nested := range v {
+			addJSONFormValue(r, key+"."+k, nested)
+		}
+	case []interface{}:
+		for i, element := range v {
+			if _, ok := element.(map[string]interface{}); ok {
+				// row of a group item
+				row := fmt.Sprintf("%s.%d", key, i)
+				r.Form.Set(row, "")
+				r.PostForm.Set(row, "")
+				addJSONFormValue(r, row, element)
+				continue
+			}
+			addJSONFormValue(r, key, element)
+		}
+	}
+}
+
+// respondFormStatus sends an error status - as JSON error object in JSON API mode.
+func respondFormStatus(w http.ResponseWriter, r *http.Request, status int) {
+	if isJSONRequest(r) {
+		respondJSON(w, r, status, formErrorResponse{Error: http.StatusText(status)})
+		return
+	}
+	respondWithStatusText(w, status)
+}
+
+// respondJSON writes the JSON encoded value using the given status code.
+func respondJSON(w http.ResponseWriter, r *http.Request, status int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+
+	err := json.NewEncoder(w).Encode(value)
+	if err != nil {
+		Log.ErrorObjR(r, "could not write JSON response", err)
+	}
+}
+
+// formSchema is the JSON representation of a form.
+type formSchema struct {
+	Name  string           `json:"name"`
+	ID    string           `json:"id,omitempty"`
+	Items []formSchemaItem `json:"items"`
+	Steps []FormWizardStep `json:"steps,omitempty"`
+}
+
+type formSchemaItem struct {
+	Name        string `json:"name"`
+	Label       string `json:"label,omitempty"`
+	Type        string `json:"type"`
+	Value       string `json:"value"`
+	Placeholder string `json:"placeholder,omitempty"`
+	Help        string `json:"help,omitempty"`
+
+	Values  []string         `json:"values,omitempty"`
+	Options []FormItemOption `json:"options,omitempty"`
+
+	IsHidden   bool `json:"hidden,omitempty"`
+	IsDisabled bool `json:"disabled,omitempty"`
+
+	Constraints *formSchemaConstraints `json:"constraints,omitempty"`
+
+	ShowIf    *FormItemCondition `json:"show_if,omitempty"`
+	EnableIf  *FormItemCondition `json:"enable_if,omitempty"`
+	DependsOn []string           `json:"depends_on,omitempty"`
+
+	Group []formSchemaItem   `json:"group,omitempty"`
+	Rows  [][]formSchemaItem `json:"rows,omitempty"`
+}
+
+type formSchemaConstraints struct {
+	IsMandatory bool `json:"required,omitempty"`
+	IsNumber    bool `json:"number,omitempty"`
+	IsInteger   bool `json:"integer,omitempty"`
+	IsEmail     bool `json:"email,omitempty"`
+	IsURL       bool `json:"url,omitempty"`
+	IsDate      bool `json:"date,omitempty"`
+
+	MinValue *float64 `json:"min,omitempty"`
+	MaxValue *float64 `json:"max,omitempty"`
+
+	MinLength int    `json:"min_length,omitempty"`
+	MaxLength int    `json:"max_length,omitempty"`
+	Regexp    string `json:"pattern,omitempty"`
+
+	MaxFileSize int64    `json:"max_file_size,omitempty"`
+	FileTypes   []string `json:"file_types,omitempty"`
+}
+
+func newFormSchemaItems(items FormItems) []formSchemaItem {
+	items.applyConditions()
+
+	result := make([]formSchemaItem, len(items))
+	for i, item := range items {
+		result[i] = newFormSchemaItem(item)
+	}
+	return result
+}
+
+func newFormSchemaItem(item FormItem) formSchemaItem {
+	result := formSchemaItem{
+		Name:        item.Name,
+		Label:       item.Label,
+		Type:        item.InputType,
+		Value:       item.Value,
+		Placeholder: item.Placeholder,
+		Help:        item.Help,
+		Values:      item.Values,
+		Options:     item.Options,
+		IsHidden:    item.IsHidden || !item.IsVisible(),
+		IsDisabled:  item.IsDisabled,
+		ShowIf:      item.ShowIf,
+		EnableIf:    item.EnableIf,
+	}
+	if t := item.HTMLInputType(); t != "" {
+		result.Type = t
+	}
+	if item.DependentOptions != nil {
+		result.DependsOn = item.DependentOptions.Items
+	}
+
+	if c := item.Constraints; c != nil {
+		result.Constraints = &formSchemaConstraints{
+			IsMandatory: c.IsMandatory,
+			IsNumber:    c.IsNumber,
+			IsInteger:   c.IsInteger,
+			IsEmail:     c.IsEmail,
+			IsURL:       c.IsURL,
+			IsDate:      c.IsDate,
+			MinLength:   c.MinLength,
+			MaxLength:   c.MaxLength,
+			Regexp:      c.Regexp,
+			MaxFileSize: c.MaxFileSize,
+			FileTypes:   c.FileTypes,
+		}
+		if c.IsNumber || c.IsInteger {
+			// unbounded values (see FormFromStruct) are omitted
+			minValue, maxValue := c.MinValue, c.MaxValue
+			if minValue > -math.MaxFloat64 {
+				result.Constraints.MinValue = &minValue
+			}
+			if maxValue < math.MaxFloat64 {
+				result.Constraints.MaxValue = &maxValue
+			}
+		}
+	}
+
+	if item.InputType == "group" {
+		result.Group = newFormSchemaItems(item.newRow())
+		result.Rows = [][]formSchemaItem{}
+		for _, row := range item.Rows {
+			result.Rows = append(result.Rows, newFormSchemaItems(row))
+		}
+	}
+
+	return result
+}
+
+// respondFormSchema returns the JSON schema of the form including the current values.
+func respondFormSchema(w http.ResponseWriter, r *http.Request, name, id string, formSpec FormSpec, items FormItems) {
+	schema := formSchema{
+		Name:  name,
+		ID:    id,
+		Items: newFormSchemaItems(items),
+	}
+	if wizard, ok := formSpec.(FormSpecWizard); ok {
+		schema.Steps = wizard.Steps()
+	}
+
+	respondJSON(w, r, http.StatusOK, schema)
+}
+
+// formErrorResponse is the JSON representation of an invalid form submission.
+type formErrorResponse struct {
+	// general error message
+	Error string `json:"error,omitempty"`
+	// validation messages by item name (rows of group items: "<group>.<row index>.<item>")
+	Errors map[string]string `json:"errors,omitempty"`
+	// changes of a concurrently changed record
+	Conflict *formConflict `json:"conflict,omitempty"`
+}
+
+// respondFormErrors returns the validation messages of the items (status 422) or the version
+// conflict (status 409).
+func respondFormErrors(
+	w http.ResponseWriter, r *http.Request, items FormItems, errorMessage string, conflict *formConflict,
+) {
+	status := http.StatusUnprocessableEntity
+	if conflict != nil {
+		status = http.StatusConflict
+	}
+
+	response := formErrorResponse{
+		Error:    errorMessage,
+		Errors:   map[string]string{},
+		Conflict: conflict,
+	}
+	items.collectErrors("", response.Errors)
+
+	respondJSON(w, r, status, response)
+}
+
+// collectErrors adds the validation messages of all (row) items to the map.
+func (fi FormItems) collectErrors(prefix string, errors map[string]string) {
+	for _, item := range fi {
+		if item.HelpClass == "is-danger" {
+			errors[prefix+item.Name] = item.Help
+		}
+		for i, row := range item.Rows {
+			row.collectErrors(fmt.Sprintf("%s%s.%d.", prefix, item.Name, i), errors)
+		}
+	}
+}
+
+// formResult is the JSON representation of a response action.
+type formResult struct {
+	OK bool `json:"ok"`
+	// ID of the saved entity (if provided by the form specification)
+	ID string `json:"id,omitempty"`
+
+	Refresh     bool   `json:"refresh,omitempty"`
+	Redirect    string `json:"redirect,omitempty"`
+	CloseDialog bool   `json:"close_dialog,omitempty"`
+
+	Message      string `json:"message,omitempty"`
+	MessageClass string `json:"message_class,omitempty"`
+}
+
+// respondActionJSON returns the response action as JSON result.
+func respondActionJSON(w http.ResponseWriter, r *http.Request, action *ResponseAction) {
+	if action == nil {
+		respondJSON(w, r, http.StatusOK, formResult{OK: true})
+		return
+	}
+
+	if action.callback != nil {
+		action.callback(w)
+	}
+	if action.audit != nil {
+		auditLog(r, *action.audit)
+	}
+
+	respondJSON(w, r, http.StatusOK, formResult{
+		OK:           true,
+		ID:           action.id,
+		Refresh:      action.doPageRefresh,
+		Redirect:     action.redirect,
+		CloseDialog:  action.doCloseDialog,
+		Message:      action.message,
+		MessageClass: action.messageClass,
+	})
+}
@@ -0,0 +1,160 @@
+package uos
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestIsJSONRequest(t *testing.T) {
+	tests := []struct {
+		accept string
+		isJSON bool
+	}{
+		{"", false},
+		{"application/json", true},
+		{"application/json; charset=utf-8", true},
+		{"text/html", false},
+		{"text/html, application/json", false},
+		{"application/json, text/html", false},
+		{"*/*", false},
+		{"invalid;;, application/json", true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.accept, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/forms/test", nil)
+			r.Header.Set("Accept", tc.accept)
+			if isJSON := isJSONRequest(r); isJSON != tc.isJSON {
+				t.Errorf("got %t, want %t", isJSON, tc.isJSON)
+			}
+		})
+	}
+}
+
+func TestParseJSONForm(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		want        url.Values
+		isError     bool
+	}{
+		{"empty body", "application/json", "", url.Values{}, false},
+		{"not JSON", "application/x-www-form-urlencoded", `{"name": "a"}`, url.Values{}, false},
+		{
+			"scalar values",
+			"application/json",
+			`{"name": "a", "active": true, "count": 12345678901234567890, "price": 1.5, "empty": null}`,
+			url.Values{"name": {"a"}, "active": {"true"}, "count": {"12345678901234567890"}, "price": {"1.5"}},
+			false,
+		},
+		{
+			"multiple values",
+			"application/json; charset=utf-8",
+			`{"tags": ["a", "b"]}`,
+			url.Values{"tags": {"a", "b"}},
+			false,
+		},
+		{
+			"nested object",
+			"application/json",
+			`{"address": {"city": "x", "geo": {"lat": 1}}}`,
+			url.Values{"address.city": {"x"}, "address.geo.lat": {"1"}},
+			false,
+		},
+		{
+			"group rows",
+			"application/json",
+			`{"lines": [{"amount": 1, "text": "a"}, {"amount": 2}]}`,
+			url.Values{
+				"lines.0": {""}, "lines.0.amount": {"1"}, "lines.0.text": {"a"},
+				"lines.1": {""}, "lines.1.amount": {"2"},
+			},
+			false,
+		},
+		{"invalid JSON", "application/json", `{"name": `, nil, true},
+		{"no object", "application/json", `["a"]`, nil, true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/forms/test", strings.NewReader(tc.body))
+			r.Header.Set("Content-Type", tc.contentType)
+			r.Form, r.PostForm = url.Values{}, url.Values{}
+
+			err := parseJSONForm(httptest.NewRecorder(), r)
+			if isError := err != nil; isError != tc.isError {
+				t.Fatalf("got error %v, want error %t", err, tc.isError)
+			}
+			if tc.isError {
+				return
+			}
+
+			if !reflect.DeepEqual(r.Form, tc.want) {
+				t.Errorf("got form values %v, want %v", r.Form, tc.want)
+			}
+			if !reflect.DeepEqual(r.PostForm, tc.want) {
+				t.Errorf("got posted values %v, want %v", r.PostForm, tc.want)
+			}
+		})
+	}
+}
+
+func TestHandleResponseActionJSON(t *testing.T) {
+	setupTestLogging()
+
+	tests := []struct {
+		name       string
+		respond    func(w http.ResponseWriter)
+		action     *ResponseAction
+		wantStatus int
+		wantResult *formResult
+	}{
+		{"no action", nil, nil, http.StatusOK, &formResult{OK: true}},
+		{"response written by action", RespondForbidden, nil, http.StatusForbidden, nil},
+		{
+			"message",
+			nil,
+			ResponseMessage("saved", "is-success"),
+			http.StatusOK,
+			&formResult{OK: true, Message: "saved", MessageClass: "is-success"},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/actions/test", nil)
+			r.Header.Set("Accept", "application/json")
+			rec := httptest.NewRecorder()
+			w := newLoggingResponseWriter(rec)
+
+			if tc.respond != nil {
+				tc.respond(w)
+			}
+			handleResponseAction(w, r, tc.action)
+
+			if rec.Code != tc.wantStatus {
+				t.Errorf("got status %d, want %d", rec.Code, tc.wantStatus)
+			}
+			if tc.wantResult == nil {
+				if strings.Contains(rec.Body.String(), `"ok"`) {
+					t.Errorf("unexpected JSON result: %s", rec.Body.String())
+				}
+				return
+			}
+
+			var result formResult
+			if err := json.Unmarshal(rec.Body.Bytes(), &result); err != nil {
+				t.Fatal(err)
+			}
+			if result != *tc.wantResult {
+				t.Errorf("got result %+v, want %+v", result, *tc.wantResult)
+			}
+		})
+	}
+}
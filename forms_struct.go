@@ -0,0 +1,594 @@
+package uos
+
+import (
+	"context"
+	"encoding"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+// FormFromStruct returns form items for the exported fields of the given struct (or pointer to
+// a struct). The item values are set to the current field values. Embedded structs are
+// included, an embedded gorm.Model results in a hidden "id" item. Fields are configured with
+// "uos" struct tags, e.g. `uos:"label=Name,required"`:
+//
+//	name=<name>         item name, default: field name in snake case (like DB columns)
+//	label=<text>        item label, default: field name
+//	required            value is mandatory
+//	hidden              hidden item
+//	type=<input type>   input type, e.g. "password", "date", "textarea", "radio" or "switch"
+//	                    ("email", "url" and "date" also validate the format of string values)
+//	placeholder=<text>  placeholder
+//	default=<value>     value used for empty (zero) fields
+//	min=<n>, max=<n>    number range
+//	minlen=<n>, maxlen=<n>  text length
+//	regexp=<expr>       regular expression the value must match (without commas)
+//	options=<a|b|c>     valid values (enums) - rendered as select
+//	group               slice of structs: group item (one row per element)
+//	-                   field is ignored
+//
+// Supported field types: strings, integers, floats, bools (checkbox), time.Time, types
+// implementing encoding.TextMarshaler/TextUnmarshaler (enums) and pointers to these types as
+// well as string slices (multiselect) and slices of structs tagged with "group". Enum types
+// can implement FormItemOptionsProvider. Password items are not filled.
+// Panics if v is not a struct or contains an invalid regular expression.
+func FormFromStruct(v interface{}) FormItems {
+	value := reflect.Indirect(reflect.ValueOf(v))
+	if value.Kind() != reflect.Struct {
+		Log.Panic("invalid call to FormFromStruct - v must be a struct")
+	}
+
+	items := FormItems{}
+	for _, f := range structFormFields(value.Type()) {
+		fieldValue := value.FieldByIndex(f.index)
+		if f.isGroup() {
+			items = append(items, structFormGroup(f, fieldValue))
+			continue
+		}
+
+		item := newFormItem(f.name, f.label(), f.typ, f.settings)
+		switch {
+		case item.InputTypeHTML == "password":
+			// stored passwords are never sent to the client
+		case fieldValue.Type() == stringSliceType:
+			item.Values = append([]string{}, fieldValue.Interface().([]string)...)
+			item.Value = strings.Join(item.Values, ",")
+		case !fieldValue.IsZero() || f.settings["default"] == "":
+			item.Value = formatFormValue(fieldValue, item.InputTypeHTML)
+		default:
+			item.Value = f.settings["default"]
+		}
+
+		items = append(items, item)
+	}
+
+	return items
+}
+
+// FormBindError describes an invalid form item value.
+type FormBindError struct {
+	Item string
+	Err  error
+}
+
+// FormBindErrors is returned by BindForm if form item values cannot be converted.
+type FormBindErrors []FormBindError
+
+func (e FormBindErrors) Error() string {
+	messages := make([]string, len(e))
+	for i, err := range e {
+		messages[i] = fmt.Sprintf("%s: %s", err.Item, err.Err)
+	}
+	return "invalid form values: " + strings.Join(messages, ", ")
+}
+
+// BindForm converts the form item values and assigns them to the fields of the struct v points
+// to (see FormFromStruct for field configuration). Items without corresponding field are
+// ignored. If values cannot be converted, the help text of the affected items is set and
+// FormBindErrors is returned. Empty password items keep the field value (password items are
+// not filled by FormFromStruct). Rows of group items replace the elements of the slice - posted
+// row IDs must be IDs of the current elements (load the struct including the rows before
+// binding). Panics if v is not a pointer to a struct.
+func BindForm(items FormItems, v interface{}) error {
+	return bindForm(contextTranslator(context.Background()), items, v)
+}
+
+// BindFormContext binds the form items like BindForm. The help texts of items with invalid
+// values are translated into the language of the request context.
+func BindFormContext(ctx context.Context, items FormItems, v interface{}) error {
+	return bindForm(contextTranslator(ctx), items, v)
+}
+
+func bindForm(tr translateFunc, items FormItems, v interface{}) error {
+	value := reflect.ValueOf(v)
+	if value.Kind() != reflect.Ptr || value.Elem().Kind() != reflect.Struct {
+		Log.Panic("invalid call to BindForm - v must be a pointer to a struct")
+	}
+	value = value.Elem()
+
+	fields := map[string]structFormField{}
+	for _, f := range structFormFields(value.Type()) {
+		fields[f.name] = f
+	}
+
+	var bindErrors FormBindErrors
+	for i, item := range items {
+		f, ok := fields[item.Name]
+		if !ok || (item.InputTypeHTML == "password" && item.Value == "") {
+			continue
+		}
+
+		var err error
+		if field := value.FieldByIndex(f.index); f.isGroup() {
+			bindErrors = append(bindErrors, bindFormGroup(tr, item, field)...)
+			continue
+		} else if field.Type() == stringSliceType {
+			field.Set(reflect.ValueOf(append([]string{}, item.Values...)))
+		} else {
+			err = validateFormOption(f.settings, item.Value)
+			if err == nil {
+				err = parseFormValue(field, item.Value, item.InputTypeHTML)
+			}
+		}
+		if err != nil {
+			items[i].Help = tr(err.Error())
+			items[i].HelpClass = "is-danger"
+			bindErrors = append(bindErrors, FormBindError{Item: item.Name, Err: err})
+		}
+	}
+
+	if len(bindErrors) > 0 {
+		return bindErrors
+	}
+	return nil
+}
+
+// structFormField describes a struct field used as form item.
+type structFormField struct {
+	index []int
+	name  string
+	field string
+	typ   reflect.Type
+
+	settings map[string]string
+}
+
+// isGroup checks whether the field is represented by a group item.
+func (f structFormField) isGroup() bool {
+	_, isGroup := f.settings["group"]
+	return isGroup && isFormGroupType(f.typ)
+}
+
+func (f structFormField) label() string {
+	if label := f.settings["label"]; label != "" {
+		return label
+	}
+	return f.field
+}
+
+// validateFormOption checks whether the value is one of the configured options (if any).
+// The error message is a message ID (see FormMessageInvalidSelection).
+func validateFormOption(settings map[string]string, value string) error {
+	options, ok := settings["options"]
+	if !ok || value == "" || contains(strings.Split(options, "|"), value) {
+		return nil
+	}
+	return errors.New(FormMessageInvalidSelection)
+}
+
+var (
+	timeType            = reflect.TypeOf(time.Time{})
+	gormModelType       = reflect.TypeOf(gorm.Model{})
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+	optionsProviderType = reflect.TypeOf((*FormItemOptionsProvider)(nil)).Elem()
+	stringSliceType     = reflect.TypeOf([]string{})
+)
+
+// FormItemOptionsProvider can be implemented by enum types to define the options of the
+// corresponding form items (rendered as select).
+type FormItemOptionsProvider interface {
+	FormItemOptions() []FormItemOption
+}
+
+func structFormFields(t reflect.Type) []structFormField {
+	return appendStructFormFields(nil, t, nil)
+}
+
+func appendStructFormFields(fields []structFormField, t reflect.Type, index []int) []structFormField {
+	naming := schema.NamingStrategy{}
+
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		fieldIndex := append(append([]int{}, index...), i)
+
+		settings := parseTagSettings(sf.Tag.Get("uos"))
+		if _, ok := settings["-"]; ok || !sf.IsExported() {
+			continue
+		}
+
+		switch {
+		case sf.Anonymous && sf.Type == gormModelType:
+			// embedded model: only the ID is relevant
+			fields = append(fields, structFormField{
+				index:    append(fieldIndex, 0),
+				name:     "id",
+				field:    "ID",
+				typ:      sf.Type.Field(0).Type,
+				settings: map[string]string{"hidden": ""},
+			})
+			continue
+		case sf.Anonymous && sf.Type.Kind() == reflect.Struct && sf.Type != timeType:
+			fields = appendStructFormFields(fields, sf.Type, fieldIndex)
+			continue
+		}
+
+		name := settings["name"]
+		if name == "" {
+			name = naming.ColumnName("", sf.Name)
+		}
+
+		field := structFormField{
+			index:    fieldIndex,
+			name:     name,
+			field:    sf.Name,
+			typ:      sf.Type,
+			settings: settings,
+		}
+		if !isFormFieldType(sf.Type) && !field.isGroup() {
+			continue
+		}
+		fields = append(fields, field)
+	}
+
+	return fields
+}
+
+func isFormFieldType(t reflect.Type) bool {
+	if t == stringSliceType {
+		return true
+	}
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == timeType || reflect.PtrTo(t).Implements(textUnmarshalerType) {
+		return true
+	}
+
+	switch t.Kind() {
+	case reflect.String, reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+// isFormGroupType checks whether the type is a slice of structs (or pointers to structs)
+// represented by a group item.
+func isFormGroupType(t reflect.Type) bool {
+	if t.Kind() != reflect.Slice {
+		return false
+	}
+
+	elem := t.Elem()
+	if elem.Kind() == reflect.Ptr {
+		elem = elem.Elem()
+	}
+	return elem.Kind() == reflect.Struct && elem != timeType
+}
+
+// structFormGroup returns a group item for a slice of structs. Each element is a row.
+func structFormGroup(f structFormField, value reflect.Value) FormItem {
+	elem := f.typ.Elem()
+	if elem.Kind() == reflect.Ptr {
+		elem = elem.Elem()
+	}
+
+	item := FormItem{
+		InputType: "group",
+		Name:      f.name,
+		Label:     f.label(),
+		Group:     FormFromStruct(reflect.New(elem).Interface()),
+	}
+	if _, isRequired := f.settings["required"]; isRequired {
+		item.Constraints = &FormItemConstraints{IsMandatory: true}
+	}
+
+	for i := 0; i < value.Len(); i++ {
+		if row := reflect.Indirect(value.Index(i)); row.IsValid() {
+			item.Rows = append(item.Rows, FormFromStruct(row.Interface()))
+		}
+	}
+	item.Value = strconv.Itoa(len(item.Rows))
+
+	return item
+}
+
+// bindFormGroup assigns the rows of the group item to the slice field (one element per row).
+// Row IDs must be IDs of the current elements. Returns the errors of all rows (item names:
+// "<group>.<row index>.<item>").
+func bindFormGroup(tr translateFunc, item FormItem, field reflect.Value) FormBindErrors {
+	var bindErrors FormBindErrors
+
+	elemType := field.Type().Elem()
+	isPointer := elemType.Kind() == reflect.Ptr
+	if isPointer {
+		elemType = elemType.Elem()
+	}
+
+	// IDs of the current elements (rows can only reference these)
+	var idIndex []int
+	for _, f := range structFormFields(elemType) {
+		if f.name == "id" {
+			idIndex = f.index
+		}
+	}
+	currentIDs := []string{}
+	for i := 0; i < field.Len() && idIndex != nil; i++ {
+		if elem := reflect.Indirect(field.Index(i)); elem.IsValid() {
+			currentIDs = append(currentIDs, fmt.Sprint(elem.FieldByIndex(idIndex).Interface()))
+		}
+	}
+
+	slice := reflect.MakeSlice(field.Type(), len(item.Rows), len(item.Rows))
+	for i, row := range item.Rows {
+		elem := reflect.New(elemType)
+
+		var rowErrors FormBindErrors
+		if errors.As(bindForm(tr, row, elem.Interface()), &rowErrors) {
+			for _, err := range rowErrors {
+				err.Item = fmt.Sprintf("%s.%d.%s", item.Name, i, err.Item)
+				bindErrors = append(bindErrors, err)
+			}
+		}
+		if idIndex != nil {
+			id := elem.Elem().FieldByIndex(idIndex)
+			if !id.IsZero() && !contains(currentIDs, fmt.Sprint(id.Interface())) {
+				bindErrors = append(bindErrors, FormBindError{
+					Item: fmt.Sprintf("%s.%d.id", item.Name, i),
+					Err:  fmt.Errorf("unknown row '%v'", id.Interface()),
+				})
+			}
+		}
+
+		if isPointer {
+			slice.Index(i).Set(elem)
+		} else {
+			slice.Index(i).Set(elem.Elem())
+		}
+	}
+	field.Set(slice)
+
+	return bindErrors
+}
+
+// newFormItem returns a form item for a field of the given type.
+func newFormItem(name, label string, typ reflect.Type, settings map[string]string) FormItem {
+	_, isRequired := settings["required"]
+	_, isHidden := settings["hidden"]
+
+	item := FormItem{
+		InputType:     "input",
+		InputTypeHTML: "text",
+		Name:          name,
+		DefaultValue:  settings["default"],
+		Label:         label,
+		Placeholder:   settings["placeholder"],
+		IsHidden:      isHidden,
+		Constraints:   &FormItemConstraints{IsMandatory: isRequired},
+	}
+
+	if typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	switch {
+	case isHidden:
+		// no input type specific constraints
+	case typ == timeType:
+		item.InputTypeHTML = "datetime-local"
+	case reflect.PtrTo(typ).Implements(optionsProviderType):
+		item.InputType = "select"
+		item.Options = reflect.New(typ).Interface().(FormItemOptionsProvider).FormItemOptions()
+	case reflect.PtrTo(typ).Implements(textUnmarshalerType):
+		// enum - text input
+	case typ.Kind() == reflect.Bool:
+		item.InputType = "checkbox"
+	case typ.Kind() == reflect.Slice:
+		item.InputType = "multiselect"
+	default:
+		switch typ.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			item.InputTypeHTML = "number"
+			item.Constraints.IsInteger = true
+			item.Constraints.MinValue = -math.MaxFloat64
+			item.Constraints.MaxValue = math.MaxFloat64
+		case reflect.Float32, reflect.Float64:
+			item.InputTypeHTML = "number"
+			item.Constraints.IsNumber = true
+			item.Constraints.MinValue = -math.MaxFloat64
+			item.Constraints.MaxValue = math.MaxFloat64
+		}
+	}
+
+	if options, ok := settings["options"]; ok {
+		item.Options = FormOptions(strings.Split(options, "|")...)
+		if item.InputType != "multiselect" {
+			item.InputType = "select"
+		}
+	}
+
+	switch inputType := settings["type"]; inputType {
+	case "":
+	case "textarea", "select", "multiselect", "radio", "checkbox", "switch", "file":
+		item.InputType = inputType
+	default:
+		item.InputTypeHTML = inputType
+	}
+
+	// value formats of text inputs
+	if item.InputType == "input" && typ.Kind() == reflect.String {
+		switch item.InputTypeHTML {
+		case "email":
+			item.Constraints.IsEmail = true
+		case "url":
+			item.Constraints.IsURL = true
+		case "date":
+			item.Constraints.IsDate = true
+		}
+	}
+
+	if v, err := strconv.ParseFloat(settings["min"], 64); err == nil {
+		item.Min = settings["min"]
+		item.Constraints.MinValue = v
+	}
+	if v, err := strconv.ParseFloat(settings["max"], 64); err == nil {
+		item.Max = settings["max"]
+		item.Constraints.MaxValue = v
+	}
+	item.Constraints.MinLength = stringToInt(settings["minlen"], 0)
+	item.Constraints.MaxLength = stringToInt(settings["maxlen"], 0)
+	item.Constraints.Regexp = settings["regexp"]
+	if item.Constraints.Regexp != "" {
+		_, err := compileFormRegexp(item.Constraints.Regexp)
+		if err != nil {
+			Log.PanicContext(
+				"invalid form item regexp",
+				LogContext{"name": name, "regexp": item.Constraints.Regexp, "error": err},
+			)
+		}
+	}
+
+	return item
+}
+
+// timeLayouts returns the value formats of the HTML date/time input types. The first layout
+// is used for formatting.
+func timeLayouts(inputType string) []string {
+	switch inputType {
+	case "date":
+		return []string{"2006-01-02"}
+	case "time":
+		return []string{"15:04", "15:04:05"}
+	case "month":
+		return []string{"2006-01"}
+	case "datetime-local":
+		return []string{"2006-01-02T15:04", "2006-01-02T15:04:05"}
+	}
+	return []string{time.RFC3339}
+}
+
+// formatFormValue converts a field value to a form item value. Times are formatted in the local
+// time zone (like values are parsed by parseFormValue).
+func formatFormValue(v reflect.Value, inputType string) string {
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return ""
+		}
+		v = v.Elem()
+	}
+
+	if t, ok := v.Interface().(time.Time); ok {
+		if t.IsZero() {
+			return ""
+		}
+		return t.In(time.Local).Format(timeLayouts(inputType)[0])
+	}
+
+	if m, ok := v.Interface().(encoding.TextMarshaler); ok {
+		if text, err := m.MarshalText(); err == nil {
+			return string(text)
+		}
+	}
+
+	return fmt.Sprintf("%v", v.Interface())
+}
+
+// parseFormValue converts a form item value and assigns it to the field. Empty values result
+// in zero values. Error messages of invalid values are message IDs (see FormMessageInvalidValue).
+func parseFormValue(field reflect.Value, value, inputType string) error {
+	value = strings.TrimSpace(value)
+
+	if field.Kind() == reflect.Ptr {
+		if value == "" {
+			field.Set(reflect.Zero(field.Type()))
+			return nil
+		}
+		if field.IsNil() {
+			field.Set(reflect.New(field.Type().Elem()))
+		}
+		field = field.Elem()
+	}
+
+	if value == "" {
+		field.Set(reflect.Zero(field.Type()))
+		return nil
+	}
+
+	if field.Type() == timeType {
+		var err error
+		for _, layout := range timeLayouts(inputType) {
+			var t time.Time
+			t, err = time.ParseInLocation(layout, value, time.Local)
+			if err == nil {
+				field.Set(reflect.ValueOf(t))
+				return nil
+			}
+		}
+		return errors.New(FormMessageInvalidDate)
+	}
+
+	if u, ok := field.Addr().Interface().(encoding.TextUnmarshaler); ok {
+		if u.UnmarshalText([]byte(value)) != nil {
+			return errors.New(FormMessageInvalidValue)
+		}
+		return nil
+	}
+
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(value)
+	case reflect.Bool:
+		if value == "on" {
+			// checkbox without value attribute
+			field.SetBool(true)
+			return nil
+		}
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return errors.New(FormMessageInvalidValue)
+		}
+		field.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(value, 10, field.Type().Bits())
+		if err != nil {
+			return errors.New(FormMessageNoInteger)
+		}
+		field.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		u, err := strconv.ParseUint(value, 10, field.Type().Bits())
+		if err != nil {
+			return errors.New(FormMessageNoInteger)
+		}
+		field.SetUint(u)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(value, field.Type().Bits())
+		if err != nil {
+			return errors.New(FormMessageNoNumber)
+		}
+		field.SetFloat(f)
+	default:
+		return fmt.Errorf("unsupported field type %s", field.Type())
+	}
+
+	return nil
+}
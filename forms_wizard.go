@@ -0,0 +1,299 @@
+package uos
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/gorilla/securecookie"
+)
+
+// FormSpecWizard can be implemented by forms with multiple steps (wizards). The items returned
+// by Read are distributed to the steps. Each step is validated when the user continues to the
+// next step, the form is saved once after the last step (FormSpecSave or FormSpecSaveContext
+// required). Values of previous steps are kept in a signed hidden form item (no server-side
+// session required). Wizards can be rendered inline and in dialogs - the saving button of a
+// dialog continues to the next step.
+type FormSpecWizard interface {
+	// Steps returns the steps of the form.
+	Steps() []FormWizardStep
+}
+
+// FormWizardStep describes a single step of a wizard form.
+type FormWizardStep struct {
+	// display name of the step
+	Title string `json:"title"`
+	// names of the form items shown in this step (in this order). Items not assigned to any
+	// step (e.g. a hidden "id" item) are only kept in the wizard state.
+	Items []string `json:"items"`
+}
+
+// FormWizardStateItem is the name of the hidden form item containing the signed wizard state.
+const FormWizardStateItem = "_wizard"
+
+// maximum age of a wizard state (seconds)
+const formWizardMaxAge = 24 * 60 * 60
+
+// Wizard button texts (message IDs, see form messages).
+const (
+	FormMessageWizardBack = "Back"
+	FormMessageWizardNext = "Next"
+)
+
+// formWizardState contains the current step and the values of all steps.
+type formWizardState struct {
+	ID     string
+	Step   int
+	Values url.Values
+}
+
+// formWizard is the template context of a wizard form.
+type formWizard struct {
+	Steps []FormWizardStep
+	Step  int
+
+	// signed state (value of the hidden state item)
+	State string
+
+	BackText string
+	NextText string
+}
+
+// IsFirst returns true if the first step is shown.
+func (fw formWizard) IsFirst() bool {
+	return fw.Step == 0
+}
+
+// IsLast returns true if the last step is shown.
+func (fw formWizard) IsLast() bool {
+	return fw.Step == len(fw.Steps)-1
+}
+
+var (
+	wizardCodec     *securecookie.SecureCookie
+	wizardCodecOnce sync.Once
+)
+
+func getWizardCodec() *securecookie.SecureCookie {
+	wizardCodecOnce.Do(func() {
+		wizardCodec = securecookie.New(Config.Auth.hash, Config.Auth.block).
+			MaxLength(0).
+			MaxAge(formWizardMaxAge)
+	})
+	return wizardCodec
+}
+
+// encodeWizardState signs (and encrypts) the state. The state is bound to the form.
+func encodeWizardState(name string, state formWizardState) (string, error) {
+	return getWizardCodec().Encode("wizard-"+name, state)
+}
+
+func decodeWizardState(name, value string) (formWizardState, error) {
+	var state formWizardState
+	err := getWizardCodec().Decode("wizard-"+name, value, &state)
+	if state.Values == nil {
+		state.Values = url.Values{}
+	}
+	return state, err
+}
+
+// wizardFormValues returns the ID of the edited entity and the values of all wizard steps: the
+// values of the posted state combined with the posted values of the current step. Returns the
+// posted values if the request contains no valid wizard state.
+func wizardFormValues(r *http.Request, name string) (string, url.Values) {
+	state, err := decodeWizardState(name, r.Form.Get(FormWizardStateItem))
+	if err != nil {
+		return r.Form.Get("id"), r.Form
+	}
+
+	for k, v := range r.PostForm {
+		state.Values[k] = v
+	}
+	return state.ID, state.Values
+}
+
+// values returns the item values as posted by the form.
+func (fi FormItems) values() url.Values {
+	values := url.Values{}
+	for _, item := range fi {
+		switch item.InputType {
+		case "multiselect":
+			values[item.Name] = item.Values
+		case "group":
+			for name, v := range item.rowValues() {
+				values[name] = v
+			}
+		default:
+			values.Set(item.Name, item.Value)
+		}
+	}
+	return values
+}
+
+// handleWizardRead renders the first step of the wizard. The state is initialized with the
+// values of the specified entity.
+func handleWizardRead(
+	w http.ResponseWriter, r *http.Request,
+	name string, wizard FormSpecWizard, items FormItems, id, submitButton string,
+) {
+	state := formWizardState{ID: id, Values: items.values()}
+	renderWizard(w, r, name, wizard, items, state, submitButton, "", nil)
+}
+
+// handleWizardPost handles the navigation between the steps of the wizard (URL parameter
+// "nav": "back" or "next" - default). The form is saved after the last step.
+func handleWizardPost(
+	w http.ResponseWriter, r *http.Request,
+	name string, wizard FormSpecWizard, formSave FormSpecSaveContext, submitButton string,
+) {
+	state, err := decodeWizardState(name, r.Form.Get(FormWizardStateItem))
+	if err != nil {
+		Log.DebugContextR(r, "invalid wizard state", LogContext{"error": err})
+		RespondBadRequest(w)
+		return
+	}
+
+	steps := wizard.Steps()
+	if state.Step < 0 || state.Step >= len(steps) {
+		RespondBadRequest(w)
+		return
+	}
+
+	// initialize form with the current values (kept for disabled items)
+	items, err := readForm(r, formSave, state.ID)
+	if err != nil {
+		handleFormError(w, r, "could not initialize form", err)
+		return
+	}
+
+	isBack := r.URL.Query().Get("nav") == "back"
+
+	// integrate posted values of the current step (including uploaded files)
+	fileErrors := map[string]string{}
+	if !isBack {
+		fileErrors = items.storeFiles(r)
+	}
+	for _, itemName := range steps[state.Step].Items {
+		item := items.Get(itemName)
+		if item == nil {
+			continue
+		}
+
+		if item.InputType == "group" {
+			// replace all rows
+			prefix := itemName + "."
+			for key := range state.Values {
+				if strings.HasPrefix(key, prefix) {
+					delete(state.Values, key)
+				}
+			}
+			for key, posted := range r.Form {
+				if strings.HasPrefix(key, prefix) {
+					state.Values[key] = posted
+				}
+			}
+			continue
+		}
+
+		posted, ok := r.Form[itemName]
+		if item.InputType == "file" && (!ok || isBack) {
+			// keep previously uploaded file (posted files are only checked when continuing)
+			continue
+		}
+		if _, isInvalid := fileErrors[itemName]; isInvalid {
+			continue
+		}
+		state.Values[itemName] = posted
+	}
+	items.addVersionItem(state.Values)
+	items.assignValues(state.Values)
+
+	render := func(errorMessage string, conflict *formConflict) {
+		renderWizard(w, r, name, wizard, items, state, submitButton, errorMessage, conflict)
+	}
+	tr := contextTranslator(r.Context())
+
+	switch {
+	case isBack:
+		if state.Step > 0 {
+			state.Step--
+		}
+		render("", nil)
+	case state.Step < len(steps)-1:
+		// next step (if current step is valid)
+		isValid := items.validateItems(tr, func(item FormItem) bool {
+			return contains(steps[state.Step].Items, item.Name)
+		})
+		isValid = items.setFileErrors(fileErrors) && isValid
+		if isValid {
+			state.Step++
+		} else {
+			componentEvent("form", name, "validation_failed")
+		}
+		render("", nil)
+	default:
+		// last step: validate all steps and save
+		isValid := items.validateItems(tr, nil)
+		isValid = items.setFileErrors(fileErrors) && isValid
+		if !isValid {
+			componentEvent("form", name, "validation_failed")
+
+			// show (first) step with invalid item
+			for i, step := range steps {
+				for _, itemName := range step.Items {
+					if item := items.Get(itemName); item != nil && item.HelpClass == "is-danger" {
+						state.Step = i
+						render("", nil)
+						return
+					}
+				}
+			}
+			render("", nil)
+			return
+		}
+
+		saveForm(w, r, name, formSave, state.ID, items, render)
+	}
+}
+
+// renderWizard renders the current step of the wizard.
+func renderWizard(
+	w http.ResponseWriter, r *http.Request,
+	name string, wizard FormSpecWizard, items FormItems, state formWizardState,
+	submitButton, errorMessage string, conflict *formConflict,
+) {
+	encoded, err := encodeWizardState(name, state)
+	if err != nil {
+		Log.ErrorObjR(r, "could not encode wizard state", err)
+		RespondInternalServerError(w)
+		return
+	}
+
+	items.applyConditions()
+
+	steps := wizard.Steps()
+	tr := contextTranslator(r.Context())
+	fields := []formField{}
+	for _, itemName := range steps[state.Step].Items {
+		if item := items.Get(itemName); item != nil {
+			fields = append(fields, newFormField(name, submitButton, *item, items, tr))
+		}
+	}
+
+	renderFormView(w, r, formView{
+		ID:       name,
+		Items:    items,
+		Fields:   fields,
+		Button:   submitButton,
+		Error:    errorMessage,
+		Conflict: conflict,
+		Wizard: &formWizard{
+			Steps:    steps,
+			Step:     state.Step,
+			State:    encoded,
+			BackText: TR(r, FormMessageWizardBack),
+			NextText: TR(r, FormMessageWizardNext),
+		},
+	})
+}
@@ -0,0 +1,160 @@
+package uos
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// setupTestWizardCodec initializes the keys used to sign and encrypt the wizard state.
+func setupTestWizardCodec() {
+	setupTestLogging()
+	if Config.Auth.hash == nil {
+		Config.Auth.hash = []byte("0123456789abcdef0123456789abcdef")
+		Config.Auth.block = []byte("abcdef0123456789abcdef0123456789")
+	}
+	getWizardCodec()
+}
+
+type testWizard struct{}
+
+func (testWizard) Steps() []FormWizardStep {
+	return []FormWizardStep{{Title: "1", Items: []string{"name"}}, {Title: "2", Items: []string{"email"}}}
+}
+
+func TestWizardStateTampering(t *testing.T) {
+	setupTestWizardCodec()
+
+	state := formWizardState{ID: "7", Step: 1, Values: url.Values{"name": {"a"}}}
+	encoded, err := encodeWizardState("wizard", state)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tampered := []byte(encoded)
+	tampered[len(tampered)/2] ^= 1
+
+	tests := []struct {
+		name    string
+		form    string
+		value   string
+		isValid bool
+	}{
+		{"valid state", "wizard", encoded, true},
+		{"state of other form", "other", encoded, false},
+		{"modified state", "wizard", string(tampered), false},
+		{"truncated state", "wizard", encoded[:len(encoded)-4], false},
+		{"forged state", "wizard", "eyJJRCI6IjEiLCJTdGVwIjoxfQ", false},
+		{"missing state", "wizard", "", false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			decoded, err := decodeWizardState(tc.form, tc.value)
+			if isValid := err == nil; isValid != tc.isValid {
+				t.Fatalf("got valid %t (%v), want %t", isValid, err, tc.isValid)
+			}
+			if tc.isValid && !reflect.DeepEqual(decoded, state) {
+				t.Errorf("got state %+v, want %+v", decoded, state)
+			}
+			if !tc.isValid && decoded.Values == nil {
+				t.Error("values of invalid state not initialized")
+			}
+		})
+	}
+}
+
+func TestWizardPostRejectsInvalidState(t *testing.T) {
+	setupTestWizardCodec()
+
+	invalidStep, err := encodeWizardState("wizard", formWizardState{Step: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherForm, err := encodeWizardState("other", formWizardState{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		state string
+	}{
+		{"missing state", ""},
+		{"invalid signature", "invalid"},
+		{"state of other form", otherForm},
+		{"step out of range", invalidStep},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			body := url.Values{FormWizardStateItem: {tc.state}, "name": {"a"}}
+			r := httptest.NewRequest(http.MethodPost, "/forms/wizard", strings.NewReader(body.Encode()))
+			r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			if err := r.ParseForm(); err != nil {
+				t.Fatal(err)
+			}
+
+			w := httptest.NewRecorder()
+			handleWizardPost(w, r, "wizard", testWizard{}, nil, "Save")
+			if w.Code != http.StatusBadRequest {
+				t.Errorf("got status %d, want %d", w.Code, http.StatusBadRequest)
+			}
+		})
+	}
+}
+
+func TestWizardFormValues(t *testing.T) {
+	setupTestWizardCodec()
+
+	encoded, err := encodeWizardState("wizard", formWizardState{
+		ID:     "7",
+		Values: url.Values{"name": {"stored"}, "email": {"a@b.c"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		body       url.Values
+		wantID     string
+		wantValues map[string]string
+	}{
+		{
+			"valid state",
+			url.Values{FormWizardStateItem: {encoded}, "id": {"8"}, "name": {"posted"}},
+			"7",
+			map[string]string{"name": "posted", "email": "a@b.c"},
+		},
+		{
+			"invalid state",
+			url.Values{FormWizardStateItem: {"invalid"}, "id": {"8"}, "name": {"posted"}},
+			"8",
+			map[string]string{"name": "posted", "email": ""},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/forms/wizard", strings.NewReader(tc.body.Encode()))
+			r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			if err := r.ParseForm(); err != nil {
+				t.Fatal(err)
+			}
+
+			id, values := wizardFormValues(r, "wizard")
+			if id != tc.wantID {
+				t.Errorf("got ID %q, want %q", id, tc.wantID)
+			}
+			for name, want := range tc.wantValues {
+				if got := values.Get(name); got != want {
+					t.Errorf("got %s %q, want %q", name, got, want)
+				}
+			}
+		})
+	}
+}
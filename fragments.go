@@ -3,6 +3,8 @@ package uos
 import (
 	"io"
 	"net/http"
+	"os"
+	"path/filepath"
 )
 
 // FragmentSpec describes an interface a web application fragment must provide.
@@ -61,6 +63,14 @@ func getFragmentWebHandlerFunc(fragments []FragmentSpec) AppRequestHandler {
 			return
 		}
 
+		addComponentLogFields(r, "fragment", name)
+		metricsName := fragmentMetricsName(name)
+		componentRequest("fragment", metricsName)
+		defer startComponentTimer("fragment", metricsName, "total").stop()
+
+		r, span := startSpan(r, "fragment "+name)
+		defer span.End()
+
 		// process request
 		status, err := handleFragment(w, r, name, r.Form)
 		if err != nil {
@@ -74,6 +84,19 @@ func getFragmentWebHandlerFunc(fragments []FragmentSpec) AppRequestHandler {
 	}
 }
 
+// fragmentMetricsName returns the metrics label of the fragment: the name of registered
+// fragments and existing fragment templates.
+func fragmentMetricsName(name string) string {
+	if _, ok := fragmentRegistry[name]; ok {
+		return name
+	}
+	info, err := os.Stat(filepath.Join(Config.Assets.Templates, "fragment_"+name))
+	if err == nil && !info.IsDir() {
+		return name
+	}
+	return unknownComponentName
+}
+
 func handleFragment(w io.Writer, r *http.Request, name string, form Getter) (int, error) {
 	// get fragment specification
 	fragmentSpec, ok := fragmentRegistry[name]
@@ -90,8 +113,11 @@ func handleFragment(w io.Writer, r *http.Request, name string, form Getter) (int
 	}
 
 	// process fragment
+	contextTimer := startComponentTimer("fragment", name, "context")
 	obj, err := fragmentRead.GetContextObject(form)
+	contextTimer.stop()
 	if err != nil {
+		componentEvent("fragment", name, "context_error")
 		return -1, err
 	}
 
@@ -133,8 +159,9 @@ func renderFragment(w io.Writer, r *http.Request, name string, data map[string]i
 	}
 
 	// render fragment
-	err := tmpl.ExecuteTemplate(w, name, data)
+	err := executeTemplate(w, tmpl, name, data, fragmentTemplateName)
 	if err != nil {
+		componentEvent("fragment", name, "render_error")
 		Log.ErrorContextR(
 			r, "could not execute fragment template",
 			LogContext{
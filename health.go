@@ -0,0 +1,165 @@
+package uos
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// HealthCheck describes a function checking a single aspect of the application health.
+// Returns an error if the checked component is not healthy.
+type HealthCheck func(ctx context.Context) error
+
+type healthCheckEntry struct {
+	name  string
+	check HealthCheck
+}
+
+var (
+	healthCheckMutex sync.Mutex
+	healthChecks     = []healthCheckEntry{}
+
+	// 1: application accepts requests, 0: starting or shutting down
+	appReady int32
+)
+
+// RegisterHealthCheck adds a named check to the readiness endpoint ("/readyz" on the metrics
+// port). The liveness endpoint ("/healthz") does not run any checks - a failing dependency
+// must not cause restarts. Replaces an existing check with the same name.
+func RegisterHealthCheck(name string, check HealthCheck) {
+	healthCheckMutex.Lock()
+	defer healthCheckMutex.Unlock()
+
+	Log.DebugContext("register health check", LogContext{"name": name})
+
+	for i, entry := range healthChecks {
+		if entry.name == name {
+			healthChecks[i].check = check
+			return
+		}
+	}
+	healthChecks = append(healthChecks, healthCheckEntry{name: name, check: check})
+}
+
+func setupHealthChecks() {
+	RegisterHealthCheck("db", checkDatabase)
+	RegisterHealthCheck("templates", checkTemplateDirectory)
+}
+
+func checkDatabase(ctx context.Context) error {
+	if DB == nil {
+		return fmt.Errorf("database not initialized")
+	}
+
+	sqlDB, err := DB.DB()
+	if err != nil {
+		return err
+	}
+
+	return sqlDB.PingContext(ctx)
+}
+
+func checkTemplateDirectory(ctx context.Context) error {
+	if Config.Assets.Templates == "" {
+		// no application templates configured
+		return nil
+	}
+
+	info, err := os.Stat(Config.Assets.Templates)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("template path is not a directory")
+	}
+
+	return nil
+}
+
+func setAppReady(isReady bool) {
+	if isReady {
+		atomic.StoreInt32(&appReady, 1)
+	} else {
+		atomic.StoreInt32(&appReady, 0)
+	}
+}
+
+func isAppReady() bool {
+	return atomic.LoadInt32(&appReady) == 1
+}
+
+type healthStatus struct {
+	Status string            `json:"status"`
+	Checks map[string]string `json:"checks,omitempty"`
+}
+
+// runHealthChecks executes all registered checks. Returns the combined result.
+func runHealthChecks(ctx context.Context) (healthStatus, bool) {
+	healthCheckMutex.Lock()
+	checks := make([]healthCheckEntry, len(healthChecks))
+	copy(checks, healthChecks)
+	healthCheckMutex.Unlock()
+
+	var (
+		result    = healthStatus{Status: "ok", Checks: map[string]string{}}
+		isHealthy = true
+	)
+
+	for _, entry := range checks {
+		checkCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		err := entry.check(checkCtx)
+		cancel()
+
+		if err != nil {
+			Log.WarnContext(
+				"health check failed",
+				LogContext{"name": entry.name, "error": err},
+			)
+			result.Checks[entry.name] = err.Error()
+			isHealthy = false
+			continue
+		}
+		result.Checks[entry.name] = "ok"
+	}
+
+	if !isHealthy {
+		result.Status = "failed"
+	}
+
+	return result, isHealthy
+}
+
+// healthHandler reports liveness: the process is running and handles requests.
+func healthHandler(w http.ResponseWriter, r *http.Request) {
+	respondHealthStatus(w, healthStatus{Status: "ok"}, true)
+}
+
+func readinessHandler(w http.ResponseWriter, r *http.Request) {
+	if !isAppReady() {
+		respondHealthStatus(w, healthStatus{Status: "not ready"}, false)
+		return
+	}
+
+	status, isHealthy := runHealthChecks(r.Context())
+
+	respondHealthStatus(w, status, isHealthy)
+}
+
+func respondHealthStatus(w http.ResponseWriter, status healthStatus, isHealthy bool) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+
+	if !isHealthy {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+
+	err := json.NewEncoder(w).Encode(status)
+	if err != nil {
+		Log.ErrorObj("could not write health status", err)
+	}
+}
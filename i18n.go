@@ -1,6 +1,10 @@
 package uos
 
 import (
+	"context"
+	"fmt"
+	"net/http"
+
 	"github.com/vorlif/spreak"
 	"golang.org/x/text/language"
 )
@@ -66,3 +70,37 @@ func (l *wrappedLocalizer) Lang() string {
 func (l *wrappedLocalizer) Tr(message string, vars ...interface{}) string {
 	return l.loc.Getf(message, vars...)
 }
+
+// contextLanguage returns the language of the request context (language of the logged in user
+// or client language).
+func contextLanguage(ctx context.Context) string {
+	lang, _ := ctx.Value(ctxClientLanguage).(string)
+	if user, ok := ctx.Value(ctxAppUser).(AppUser); ok && user.Language != "" {
+		lang = user.Language
+	}
+	return lang
+}
+
+// translateFunc translates and formats a message.
+type translateFunc func(message string, vars ...interface{}) string
+
+// contextTranslator returns the translation function for the language of the request context.
+// Without i18n configuration messages are only formatted.
+func contextTranslator(ctx context.Context) translateFunc {
+	if i18n == nil {
+		return func(message string, vars ...interface{}) string {
+			if len(vars) == 0 {
+				return message
+			}
+			return fmt.Sprintf(message, vars...)
+		}
+	}
+
+	return getLocalizer(contextLanguage(ctx)).Tr
+}
+
+// TR translates the message (message ID of the PO files) into the language of the request and
+// formats it using the given values. Without i18n configuration the message is only formatted.
+func TR(r *http.Request, message string, vars ...interface{}) string {
+	return contextTranslator(r.Context())(message, vars...)
+}
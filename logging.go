@@ -1,6 +1,7 @@
 package uos
 
 import (
+	"context"
 	"fmt"
 	"net"
 	"net/http"
@@ -9,6 +10,7 @@ import (
 
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type internalLogger struct{}
@@ -19,28 +21,16 @@ var Log *internalLogger
 func setupLogging() {
 	zerolog.TimeFieldFormat = zerolog.TimeFormatUnix
 
-	if Config.Logging.UseConsole {
-		log.Logger = log.Output(zerolog.ConsoleWriter{Out: os.Stderr})
-	}
+	setupLogSinks()
 
-	switch Config.Logging.Level {
-	case "panic":
-		zerolog.SetGlobalLevel(zerolog.PanicLevel)
-	case "fatal":
-		zerolog.SetGlobalLevel(zerolog.FatalLevel)
-	case "error":
-		zerolog.SetGlobalLevel(zerolog.ErrorLevel)
-	case "warn":
-		zerolog.SetGlobalLevel(zerolog.WarnLevel)
-	case "info":
-		zerolog.SetGlobalLevel(zerolog.InfoLevel)
-	case "debug":
-		zerolog.SetGlobalLevel(zerolog.DebugLevel)
-	case "trace":
-		zerolog.SetGlobalLevel(zerolog.TraceLevel)
-	default:
-		zerolog.SetGlobalLevel(zerolog.DebugLevel)
+	level, err := parseLogLevel(Config.Logging.Level)
+	if err != nil {
+		level = zerolog.DebugLevel
 	}
+	logLevels.setBase(level)
+
+	setupLogSampling()
+	setupTrustedProxies()
 
 	Log = &internalLogger{}
 }
@@ -53,13 +43,100 @@ func errorLogContext(err error) LogContext {
 }
 
 func (lc LogContext) request(r *http.Request) LogContext {
+	return lc.requestContext(r.Context())
+}
+
+// requestContext adds the request ID and all request-scoped log fields (see AddLogFields) of
+// the given request context.
+func (lc LogContext) requestContext(ctx context.Context) LogContext {
 	if lc == nil {
 		lc = LogContext{}
 	}
-	lc["request"] = r.Context().Value(ctxRequestID)
+	lc["request"] = ctx.Value(ctxRequestID)
+
+	if spanContext := trace.SpanContextFromContext(ctx); spanContext.IsValid() {
+		lc["trace"] = spanContext.TraceID().String()
+		lc["span"] = spanContext.SpanID().String()
+	}
+
+	if fields, ok := ctx.Value(ctxLogFields).(*requestLogFields); ok {
+		fields.mutex.Lock()
+		for k, v := range fields.fields {
+			if _, exists := lc[k]; !exists {
+				lc[k] = v
+			}
+		}
+		fields.mutex.Unlock()
+	}
+
 	return lc
 }
 
+// writeLog writes a log message if the level is enabled for the given request context (which
+// can be nil) and the message is not suppressed by sampling/deduplication.
+func writeLog(ctx context.Context, level zerolog.Level, message string, context LogContext) {
+	if !logLevels.isEnabled(ctx, level) {
+		terminateLog(level, message)
+		return
+	}
+
+	context, ok := logSampler.filter(level, message, context)
+	if !ok {
+		terminateLog(level, message)
+		return
+	}
+
+	appendLogContext(newLogEvent(level), message, context)
+}
+
+// terminateLog panics or exits for messages at level 'panic' or 'fatal' that are not written
+// (disabled log level) - like zerolog does after writing them.
+func terminateLog(level zerolog.Level, message string) {
+	switch level {
+	case zerolog.PanicLevel:
+		panic(message)
+	case zerolog.FatalLevel:
+		os.Exit(1)
+	}
+}
+
+// newLogEvent creates a log event for the specified level.
+func newLogEvent(level zerolog.Level) *zerolog.Event {
+	switch level {
+	case zerolog.PanicLevel:
+		return log.Panic()
+	case zerolog.FatalLevel:
+		return log.Fatal()
+	case zerolog.ErrorLevel:
+		return log.Error()
+	case zerolog.WarnLevel:
+		return log.Warn()
+	case zerolog.InfoLevel:
+		return log.Info()
+	case zerolog.DebugLevel:
+		return log.Debug()
+	}
+
+	return log.Trace()
+}
+
+// countLogMessage updates the log message metrics for the specified level.
+func countLogMessage(level zerolog.Level) {
+	switch level {
+	case zerolog.PanicLevel, zerolog.FatalLevel:
+		Metrics.CounterInc(mLogMessage)
+		Metrics.CounterInc(mLogMessagePanic)
+	case zerolog.ErrorLevel:
+		Metrics.CounterInc(mLogMessage)
+		Metrics.CounterInc(mLogMessageError)
+	case zerolog.WarnLevel:
+		Metrics.CounterInc(mLogMessage)
+		Metrics.CounterInc(mLogMessageWarning)
+	case zerolog.InfoLevel:
+		Metrics.CounterInc(mLogMessage)
+	}
+}
+
 func appendLogContext(l *zerolog.Event, message string, context LogContext) {
 	event := l
 
@@ -116,46 +193,42 @@ func appendLogContext(l *zerolog.Event, message string, context LogContext) {
 
 // PanicContext logs the specified message and context at log level 'panic'.
 func (internalLogger) PanicContext(message string, context LogContext) {
-	Metrics.CounterInc(mLogMessage)
-	Metrics.CounterInc(mLogMessagePanic)
-	appendLogContext(log.Panic(), message, context)
+	countLogMessage(zerolog.PanicLevel)
+	writeLog(nil, zerolog.PanicLevel, message, context)
 }
 
 // FatalContext logs the specified message and context at log level 'fatal'.
 func (internalLogger) FatalContext(message string, context LogContext) {
-	Metrics.CounterInc(mLogMessage)
-	Metrics.CounterInc(mLogMessagePanic)
-	appendLogContext(log.Fatal(), message, context)
+	countLogMessage(zerolog.FatalLevel)
+	writeLog(nil, zerolog.FatalLevel, message, context)
 }
 
 // ErrorContext logs the specified message and context at log level 'error'.
 func (internalLogger) ErrorContext(message string, context LogContext) {
-	Metrics.CounterInc(mLogMessage)
-	Metrics.CounterInc(mLogMessageError)
-	appendLogContext(log.Error(), message, context)
+	countLogMessage(zerolog.ErrorLevel)
+	writeLog(nil, zerolog.ErrorLevel, message, context)
 }
 
 // WarnContext logs the specified message and context at log level 'warning'.
 func (internalLogger) WarnContext(message string, context LogContext) {
-	Metrics.CounterInc(mLogMessage)
-	Metrics.CounterInc(mLogMessageWarning)
-	appendLogContext(log.Warn(), message, context)
+	countLogMessage(zerolog.WarnLevel)
+	writeLog(nil, zerolog.WarnLevel, message, context)
 }
 
 // InfoContext logs the specified message and context at log level 'info'.
 func (internalLogger) InfoContext(message string, context LogContext) {
-	Metrics.CounterInc(mLogMessage)
-	appendLogContext(log.Info(), message, context)
+	countLogMessage(zerolog.InfoLevel)
+	writeLog(nil, zerolog.InfoLevel, message, context)
 }
 
 // DebugContext logs the specified message and context at log level 'debug'.
 func (internalLogger) DebugContext(message string, context LogContext) {
-	appendLogContext(log.Debug(), message, context)
+	writeLog(nil, zerolog.DebugLevel, message, context)
 }
 
 // TraceContext logs the specified message and context at log level 'trace'.
 func (internalLogger) TraceContext(message string, context LogContext) {
-	appendLogContext(log.Trace(), message, context)
+	writeLog(nil, zerolog.TraceLevel, message, context)
 }
 
 // Panic logs the specified message at log level 'panic'.
@@ -226,46 +299,42 @@ func (l *internalLogger) DebugError(message string, err error) {
 
 // PanicContext logs the specified message and context at log level 'panic'.
 func (internalLogger) PanicContextR(r *http.Request, message string, context LogContext) {
-	Metrics.CounterInc(mLogMessage)
-	Metrics.CounterInc(mLogMessagePanic)
-	appendLogContext(log.Panic(), message, context.request(r))
+	countLogMessage(zerolog.PanicLevel)
+	writeLog(r.Context(), zerolog.PanicLevel, message, context.request(r))
 }
 
 // FatalContext logs the specified message and context at log level 'fatal'.
 func (internalLogger) FatalContextR(r *http.Request, message string, context LogContext) {
-	Metrics.CounterInc(mLogMessage)
-	Metrics.CounterInc(mLogMessagePanic)
-	appendLogContext(log.Fatal(), message, context.request(r))
+	countLogMessage(zerolog.FatalLevel)
+	writeLog(r.Context(), zerolog.FatalLevel, message, context.request(r))
 }
 
 // ErrorContext logs the specified message and context at log level 'error'.
 func (internalLogger) ErrorContextR(r *http.Request, message string, context LogContext) {
-	Metrics.CounterInc(mLogMessage)
-	Metrics.CounterInc(mLogMessageError)
-	appendLogContext(log.Error(), message, context.request(r))
+	countLogMessage(zerolog.ErrorLevel)
+	writeLog(r.Context(), zerolog.ErrorLevel, message, context.request(r))
 }
 
 // WarnContext logs the specified message and context at log level 'warning'.
 func (internalLogger) WarnContextR(r *http.Request, message string, context LogContext) {
-	Metrics.CounterInc(mLogMessage)
-	Metrics.CounterInc(mLogMessageWarning)
-	appendLogContext(log.Warn(), message, context.request(r))
+	countLogMessage(zerolog.WarnLevel)
+	writeLog(r.Context(), zerolog.WarnLevel, message, context.request(r))
 }
 
 // InfoContext logs the specified message and context at log level 'info'.
 func (internalLogger) InfoContextR(r *http.Request, message string, context LogContext) {
-	Metrics.CounterInc(mLogMessage)
-	appendLogContext(log.Info(), message, context.request(r))
+	countLogMessage(zerolog.InfoLevel)
+	writeLog(r.Context(), zerolog.InfoLevel, message, context.request(r))
 }
 
 // DebugContext logs the specified message and context at log level 'debug'.
 func (internalLogger) DebugContextR(r *http.Request, message string, context LogContext) {
-	appendLogContext(log.Debug(), message, context.request(r))
+	writeLog(r.Context(), zerolog.DebugLevel, message, context.request(r))
 }
 
 // TraceContext logs the specified message and context at log level 'trace'.
 func (internalLogger) TraceContextR(r *http.Request, message string, context LogContext) {
-	appendLogContext(log.Trace(), message, context.request(r))
+	writeLog(r.Context(), zerolog.TraceLevel, message, context.request(r))
 }
 
 // PanicR logs the specified message at log level 'panic'. Includes request ID as context.
@@ -334,3 +403,8 @@ func (l *internalLogger) InfoErrorR(r *http.Request, message string, err error)
 func (l *internalLogger) DebugErrorR(r *http.Request, message string, err error) {
 	l.DebugContextR(r, message, errorLogContext(err))
 }
+
+func cleanupLogging() {
+	cleanupLogSampling()
+	cleanupLogSinks()
+}
@@ -0,0 +1,296 @@
+package uos
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// LogLevelOverride temporarily enables a more verbose log level for a single request ID or user.
+type LogLevelOverride struct {
+	// request ID the override applies to (see "X-Request-ID" header)
+	RequestID string `json:"request,omitempty"`
+	// user ID the override applies to
+	UserID uint `json:"user,omitempty"`
+
+	// log level (panic, fatal, error, warn, info, debug, trace)
+	Level string `json:"level"`
+	// end of the override
+	Expiration time.Time `json:"expiration"`
+
+	level zerolog.Level
+}
+
+type logLevelState struct {
+	mutex sync.RWMutex
+
+	base      zerolog.Level
+	overrides []LogLevelOverride
+}
+
+var logLevels = &logLevelState{base: zerolog.DebugLevel}
+
+func parseLogLevel(level string) (zerolog.Level, error) {
+	switch level {
+	case "panic":
+		return zerolog.PanicLevel, nil
+	case "fatal":
+		return zerolog.FatalLevel, nil
+	case "error":
+		return zerolog.ErrorLevel, nil
+	case "warn":
+		return zerolog.WarnLevel, nil
+	case "info":
+		return zerolog.InfoLevel, nil
+	case "debug":
+		return zerolog.DebugLevel, nil
+	case "trace":
+		return zerolog.TraceLevel, nil
+	}
+
+	return zerolog.NoLevel, fmt.Errorf("invalid log level: '%s'", level)
+}
+
+func (s *logLevelState) setBase(level zerolog.Level) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.base = level
+	s.apply()
+}
+
+// apply removes expired overrides and sets the zerolog global level to the most verbose
+// active level. Filtering of scoped overrides is done in isEnabled. Mutex must be locked.
+func (s *logLevelState) apply() {
+	var (
+		now    = time.Now()
+		global = s.base
+		active = []LogLevelOverride{}
+	)
+
+	for _, o := range s.overrides {
+		if now.After(o.Expiration) {
+			continue
+		}
+		active = append(active, o)
+
+		if o.level < global {
+			global = o.level
+		}
+	}
+
+	s.overrides = active
+	zerolog.SetGlobalLevel(global)
+}
+
+func (s *logLevelState) isEnabled(ctx context.Context, level zerolog.Level) bool {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	if level >= s.base {
+		return true
+	}
+	if len(s.overrides) == 0 || ctx == nil {
+		return false
+	}
+
+	// check for matching override
+	var (
+		requestID, _  = ctx.Value(ctxRequestID).(string)
+		user, hasUser = ctx.Value(ctxAppUser).(AppUser)
+		now           = time.Now()
+	)
+
+	for _, o := range s.overrides {
+		if level < o.level || now.After(o.Expiration) {
+			continue
+		}
+		if o.RequestID != "" && o.RequestID == requestID {
+			return true
+		}
+		if o.UserID > 0 && hasUser && o.UserID == user.ID {
+			return true
+		}
+	}
+
+	return false
+}
+
+// SetLogLevel changes the application log level at runtime.
+func SetLogLevel(level string) error {
+	zLevel, err := parseLogLevel(level)
+	if err != nil {
+		return err
+	}
+
+	Log.InfoContext("change log level", LogContext{"loglevel": level})
+	logLevels.setBase(zLevel)
+
+	return nil
+}
+
+// GetLogLevel returns the current application log level.
+func GetLogLevel() string {
+	logLevels.mutex.RLock()
+	defer logLevels.mutex.RUnlock()
+
+	return logLevels.base.String()
+}
+
+// AddLogLevelOverride enables the specified log level for a request ID or user for the given
+// duration. Returns an error if neither request ID nor user is specified or the request ID is
+// invalid (see "X-Request-ID" header).
+func AddLogLevelOverride(override LogLevelOverride, duration time.Duration) error {
+	if override.RequestID == "" && override.UserID == 0 {
+		return fmt.Errorf("log level override requires request ID or user ID")
+	}
+	if override.RequestID != "" && !isValidRequestID(override.RequestID) {
+		return fmt.Errorf("invalid request ID: '%s'", override.RequestID)
+	}
+	if duration <= 0 {
+		return fmt.Errorf("log level override requires a positive duration")
+	}
+
+	level, err := parseLogLevel(override.Level)
+	if err != nil {
+		return err
+	}
+
+	override.level = level
+	override.Expiration = time.Now().Add(duration)
+
+	Log.InfoContext(
+		"add log level override",
+		LogContext{
+			"request":    override.RequestID,
+			"user":       override.UserID,
+			"loglevel":   override.Level,
+			"expiration": override.Expiration,
+		},
+	)
+
+	logLevels.mutex.Lock()
+	logLevels.overrides = append(logLevels.overrides, override)
+	logLevels.apply()
+	logLevels.mutex.Unlock()
+
+	// reset global level after expiration
+	time.AfterFunc(duration, func() {
+		logLevels.mutex.Lock()
+		defer logLevels.mutex.Unlock()
+
+		logLevels.apply()
+	})
+
+	return nil
+}
+
+// GetLogLevelOverrides returns the list of active log level overrides.
+func GetLogLevelOverrides() []LogLevelOverride {
+	logLevels.mutex.RLock()
+	defer logLevels.mutex.RUnlock()
+
+	var (
+		now    = time.Now()
+		result = []LogLevelOverride{}
+	)
+	for _, o := range logLevels.overrides {
+		if now.Before(o.Expiration) {
+			result = append(result, o)
+		}
+	}
+
+	return result
+}
+
+// ClearLogLevelOverrides removes all log level overrides.
+func ClearLogLevelOverrides() {
+	Log.Info("clear log level overrides")
+
+	logLevels.mutex.Lock()
+	defer logLevels.mutex.Unlock()
+
+	logLevels.overrides = nil
+	logLevels.apply()
+}
+
+// isAdminRequest checks the bearer token of a request to the monitoring admin endpoints.
+func isAdminRequest(r *http.Request) bool {
+	if Config.Monitoring.AdminToken == "" {
+		return false
+	}
+
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return subtle.ConstantTimeCompare([]byte(token), []byte(Config.Monitoring.AdminToken)) == 1
+}
+
+// logLevelAdminHandler provides the current log level configuration (GET), changes the log
+// level or adds an override (POST) and removes all overrides (DELETE).
+//
+// POST parameters: level, request (optional), user (optional), duration (e.g. "15m", required
+// for overrides).
+func logLevelAdminHandler(w http.ResponseWriter, r *http.Request) {
+	if !isAdminRequest(r) {
+		Log.WarnContext("unauthorized admin request", LogContext{"url": r.URL.Path})
+		respondWithStatusText(w, http.StatusUnauthorized)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		// return current state (below)
+	case http.MethodPost:
+		err := r.ParseForm()
+		if err != nil {
+			RespondBadRequest(w)
+			return
+		}
+
+		var (
+			level     = r.Form.Get("level")
+			requestID = r.Form.Get("request")
+			userID    = stringToInt(r.Form.Get("user"), 0)
+		)
+
+		if requestID == "" && userID <= 0 {
+			err = SetLogLevel(level)
+		} else {
+			var duration time.Duration
+			duration, err = time.ParseDuration(r.Form.Get("duration"))
+			if err == nil {
+				err = AddLogLevelOverride(
+					LogLevelOverride{RequestID: requestID, UserID: uint(userID), Level: level},
+					duration,
+				)
+			}
+		}
+		if err != nil {
+			Log.WarnError("invalid log level change request", err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	case http.MethodDelete:
+		ClearLogLevelOverrides()
+	default:
+		RespondNotImplemented(w)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	err := json.NewEncoder(w).Encode(
+		map[string]interface{}{
+			"level":     GetLogLevel(),
+			"overrides": GetLogLevelOverrides(),
+		},
+	)
+	if err != nil {
+		Log.ErrorObj("could not write log level state", err)
+	}
+}
@@ -0,0 +1,215 @@
+package uos
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/rs/zerolog"
+)
+
+// requestLogFields contains key-value information included in all log messages of a request.
+// Stored as pointer in the request context - fields added later in the request handling chain
+// are visible to all following log calls.
+type requestLogFields struct {
+	mutex  sync.Mutex
+	fields LogContext
+}
+
+func newRequestLogFields(r *http.Request) *requestLogFields {
+	return &requestLogFields{
+		fields: LogContext{
+			"route":  r.URL.Path,
+			"client": clientIP(r),
+		},
+	}
+}
+
+// AddLogFields adds key-value information to all following log messages of the given request.
+// Does nothing if the request was not handled by the framework middleware.
+func AddLogFields(r *http.Request, fields LogContext) {
+	addLogFieldsContext(r.Context(), fields)
+}
+
+func addLogFieldsContext(ctx context.Context, fields LogContext) {
+	requestFields, ok := ctx.Value(ctxLogFields).(*requestLogFields)
+	if !ok {
+		return
+	}
+
+	requestFields.mutex.Lock()
+	defer requestFields.mutex.Unlock()
+
+	for k, v := range fields {
+		requestFields.fields[k] = v
+	}
+}
+
+// addComponentLogFields marks the following log messages of the request with the given
+// component type (e.g. "form") and name.
+func addComponentLogFields(r *http.Request, component, name string) {
+	AddLogFields(r, LogContext{"component": component, "component_name": name})
+}
+
+// trusted reverse proxies (see LogConfiguration.TrustedProxies)
+var trustedProxies []*net.IPNet
+
+func setupTrustedProxies() {
+	trustedProxies = nil
+	for _, proxy := range Config.Logging.TrustedProxies {
+		if !strings.Contains(proxy, "/") {
+			ip := net.ParseIP(proxy)
+			if ip == nil {
+				panic(fmt.Sprintf("invalid trusted proxy: '%s'", proxy))
+			}
+			trustedProxies = append(trustedProxies, &net.IPNet{IP: ip, Mask: net.CIDRMask(len(ip)*8, len(ip)*8)})
+			continue
+		}
+
+		_, network, err := net.ParseCIDR(proxy)
+		if err != nil {
+			panic(fmt.Sprintf("invalid trusted proxy: '%s'", proxy))
+		}
+		trustedProxies = append(trustedProxies, network)
+	}
+}
+
+func isTrustedProxy(address string) bool {
+	ip := net.ParseIP(address)
+	if ip == nil {
+		return false
+	}
+	for _, network := range trustedProxies {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// remoteHost returns the host part of the remote address of the request.
+func remoteHost(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// clientIP returns the IP address of the client. The "X-Forwarded-For" header is only used if
+// the request was sent by a trusted proxy - the last entry not added by a trusted proxy is
+// returned.
+func clientIP(r *http.Request) string {
+	client := remoteHost(r)
+	if !isTrustedProxy(client) {
+		return client
+	}
+
+	forwarded := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(forwarded) - 1; i >= 0; i-- {
+		address := strings.TrimSpace(forwarded[i])
+		if address == "" {
+			continue
+		}
+		client = address
+		if !isTrustedProxy(address) {
+			break
+		}
+	}
+	return client
+}
+
+// RequestLogger logs messages including all request-scoped information: request ID, route,
+// client IP, user ID, component and fields added using AddLogFields or With.
+type RequestLogger struct {
+	ctx context.Context
+}
+
+// LogFromContext returns the request-scoped logger for the given request context.
+func LogFromContext(ctx context.Context) *RequestLogger {
+	return &RequestLogger{ctx: ctx}
+}
+
+// LogFromRequest returns the request-scoped logger for the given request.
+func LogFromRequest(r *http.Request) *RequestLogger {
+	return LogFromContext(r.Context())
+}
+
+// With adds key-value information to all following log messages of the request.
+func (l *RequestLogger) With(fields LogContext) *RequestLogger {
+	addLogFieldsContext(l.ctx, fields)
+	return l
+}
+
+func (l *RequestLogger) log(level zerolog.Level, message string, context LogContext) {
+	countLogMessage(level)
+	writeLog(l.ctx, level, message, context.requestContext(l.ctx))
+}
+
+// ErrorContext logs the specified message and context at log level 'error'.
+func (l *RequestLogger) ErrorContext(message string, context LogContext) {
+	l.log(zerolog.ErrorLevel, message, context)
+}
+
+// WarnContext logs the specified message and context at log level 'warning'.
+func (l *RequestLogger) WarnContext(message string, context LogContext) {
+	l.log(zerolog.WarnLevel, message, context)
+}
+
+// InfoContext logs the specified message and context at log level 'info'.
+func (l *RequestLogger) InfoContext(message string, context LogContext) {
+	l.log(zerolog.InfoLevel, message, context)
+}
+
+// DebugContext logs the specified message and context at log level 'debug'.
+func (l *RequestLogger) DebugContext(message string, context LogContext) {
+	l.log(zerolog.DebugLevel, message, context)
+}
+
+// TraceContext logs the specified message and context at log level 'trace'.
+func (l *RequestLogger) TraceContext(message string, context LogContext) {
+	l.log(zerolog.TraceLevel, message, context)
+}
+
+// Error logs the specified message at log level 'error'.
+func (l *RequestLogger) Error(message string) {
+	l.ErrorContext(message, nil)
+}
+
+// Warn logs the specified message at log level 'warning'.
+func (l *RequestLogger) Warn(message string) {
+	l.WarnContext(message, nil)
+}
+
+// Info logs the specified message at log level 'info'.
+func (l *RequestLogger) Info(message string) {
+	l.InfoContext(message, nil)
+}
+
+// Debug logs the specified message at log level 'debug'.
+func (l *RequestLogger) Debug(message string) {
+	l.DebugContext(message, nil)
+}
+
+// Trace logs the specified message at log level 'trace'.
+func (l *RequestLogger) Trace(message string) {
+	l.TraceContext(message, nil)
+}
+
+// ErrorObj logs the specified message and error at log level 'error'.
+func (l *RequestLogger) ErrorObj(message string, err error) {
+	l.ErrorContext(message, errorLogContext(err))
+}
+
+// WarnError logs the specified message and error at log level 'warning'.
+func (l *RequestLogger) WarnError(message string, err error) {
+	l.WarnContext(message, errorLogContext(err))
+}
+
+// DebugError logs the specified message and error at log level 'debug'.
+func (l *RequestLogger) DebugError(message string, err error) {
+	l.DebugContext(message, errorLogContext(err))
+}
@@ -0,0 +1,231 @@
+package uos
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// LogSamplingConfiguration specifies sampling and deduplication of frequent log messages.
+// Warnings and errors are never sampled or deduplicated.
+type LogSamplingConfiguration struct {
+	// highest sampled level: "trace", "debug" or "info" (default)
+	Level string `json:"level"`
+	// rule for all messages without specific rule (burst 0: no sampling)
+	Default LogSamplingRule `json:"default"`
+	// rules for specific messages (key: message text, e.g. "received request")
+	Messages map[string]LogSamplingRule `json:"messages"`
+
+	// suppress identical messages (same level, text and error) within the deduplication window.
+	// A summary "repeated N times" is logged afterwards.
+	Deduplicate bool `json:"deduplicate"`
+	// deduplication window, e.g. "30s" (default: 10s)
+	DeduplicateWindow string `json:"deduplicate_window"`
+}
+
+// LogSamplingRule limits the number of log messages with the same key (token bucket).
+type LogSamplingRule struct {
+	// number of messages logged before sampling starts
+	Burst int `json:"burst"`
+	// number of additional messages per second
+	Rate float64 `json:"rate"`
+}
+
+type logBucket struct {
+	tokens  float64
+	last    time.Time
+	dropped int
+}
+
+type logDuplicate struct {
+	level   zerolog.Level
+	message string
+	first   time.Time
+	count   int
+}
+
+type logSamplingState struct {
+	mutex sync.Mutex
+
+	isActive bool
+	maxLevel zerolog.Level
+
+	defaultRule LogSamplingRule
+	rules       map[string]LogSamplingRule
+	buckets     map[string]*logBucket
+
+	deduplicate bool
+	window      time.Duration
+	duplicates  map[string]*logDuplicate
+
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+var logSampler = &logSamplingState{}
+
+func setupLogSampling() {
+	c := Config.Logging.Sampling
+
+	maxLevel := zerolog.InfoLevel
+	if c.Level != "" {
+		level, err := parseLogLevel(c.Level)
+		if err != nil || level > zerolog.InfoLevel {
+			panic(fmt.Sprintf("invalid log sampling level: '%s'", c.Level))
+		}
+		maxLevel = level
+	}
+
+	window := 10 * time.Second
+	if c.DeduplicateWindow != "" {
+		var err error
+		window, err = time.ParseDuration(c.DeduplicateWindow)
+		if err != nil || window <= 0 {
+			panic(fmt.Sprintf("invalid log deduplication window: '%s'", c.DeduplicateWindow))
+		}
+	}
+
+	rules := c.Messages
+	if rules == nil {
+		rules = map[string]LogSamplingRule{}
+	}
+
+	logSampler = &logSamplingState{
+		isActive: c.Default.Burst > 0 || len(c.Messages) > 0 || c.Deduplicate,
+		maxLevel: maxLevel,
+
+		defaultRule: c.Default,
+		rules:       rules,
+		buckets:     map[string]*logBucket{},
+
+		deduplicate: c.Deduplicate,
+		window:      window,
+		duplicates:  map[string]*logDuplicate{},
+	}
+
+	if logSampler.deduplicate {
+		logSampler.stop = make(chan struct{})
+		go logSampler.flushPeriodically(logSampler.stop)
+	}
+}
+
+func cleanupLogSampling() {
+	s := logSampler
+	if s.stop != nil {
+		s.stopOnce.Do(func() { close(s.stop) })
+	}
+	s.flush(true)
+}
+
+// filter decides whether a message is written. Adds the number of previously dropped messages
+// to the context of the next sampled message.
+func (s *logSamplingState) filter(level zerolog.Level, message string, context LogContext) (LogContext, bool) {
+	if !s.isActive || level > s.maxLevel {
+		return context, true
+	}
+
+	now := time.Now()
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	// sampling
+	rule, ok := s.rules[message]
+	if !ok {
+		rule = s.defaultRule
+	}
+	if rule.Burst > 0 {
+		bucket, ok := s.buckets[message]
+		if !ok {
+			bucket = &logBucket{tokens: float64(rule.Burst), last: now}
+			s.buckets[message] = bucket
+		}
+
+		// refill bucket
+		bucket.tokens += now.Sub(bucket.last).Seconds() * rule.Rate
+		if bucket.tokens > float64(rule.Burst) {
+			bucket.tokens = float64(rule.Burst)
+		}
+		bucket.last = now
+
+		if bucket.tokens < 1 {
+			bucket.dropped++
+			return context, false
+		}
+		bucket.tokens--
+
+		if bucket.dropped > 0 {
+			if context == nil {
+				context = LogContext{}
+			}
+			context["sampled_dropped"] = bucket.dropped
+			bucket.dropped = 0
+		}
+	}
+
+	// deduplication
+	if s.deduplicate {
+		key := fmt.Sprintf("%s|%s|%v", level, message, context["error"])
+
+		duplicate, ok := s.duplicates[key]
+		if ok && now.Sub(duplicate.first) < s.window {
+			duplicate.count++
+			return context, false
+		}
+		if ok && duplicate.count > 0 {
+			// window expired, but not flushed yet
+			writeRepeatedSummary(*duplicate)
+		}
+
+		s.duplicates[key] = &logDuplicate{level: level, message: message, first: now}
+	}
+
+	return context, true
+}
+
+func (s *logSamplingState) flushPeriodically(stop chan struct{}) {
+	ticker := time.NewTicker(s.window)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.flush(false)
+		case <-stop:
+			return
+		}
+	}
+}
+
+// flush writes summaries for suppressed duplicates with an expired window (or all, if forced).
+func (s *logSamplingState) flush(all bool) {
+	now := time.Now()
+
+	s.mutex.Lock()
+	summaries := []logDuplicate{}
+	for key, duplicate := range s.duplicates {
+		if !all && now.Sub(duplicate.first) < s.window {
+			continue
+		}
+		if duplicate.count > 0 {
+			summaries = append(summaries, *duplicate)
+		}
+		delete(s.duplicates, key)
+	}
+	s.mutex.Unlock()
+
+	for _, summary := range summaries {
+		writeRepeatedSummary(summary)
+	}
+}
+
+// writeRepeatedSummary logs the number of suppressed duplicates (bypasses sampling).
+func writeRepeatedSummary(d logDuplicate) {
+	appendLogContext(
+		newLogEvent(d.level),
+		fmt.Sprintf("message repeated %d times", d.count),
+		LogContext{"repeated_message": d.message, "repeated": d.count},
+	)
+}
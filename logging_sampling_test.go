@@ -0,0 +1,147 @@
+package uos
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+type logSamplingMessage struct {
+	level   zerolog.Level
+	message string
+	context LogContext
+}
+
+func TestLogSamplingFilter(t *testing.T) {
+	var (
+		info    = logSamplingMessage{zerolog.InfoLevel, "info", nil}
+		other   = logSamplingMessage{zerolog.InfoLevel, "other", nil}
+		debug   = logSamplingMessage{zerolog.DebugLevel, "debug", nil}
+		warning = logSamplingMessage{zerolog.WarnLevel, "warning", nil}
+		errorA  = logSamplingMessage{zerolog.InfoLevel, "failed", LogContext{"error": errors.New("a")}}
+		errorB  = logSamplingMessage{zerolog.InfoLevel, "failed", LogContext{"error": errors.New("b")}}
+	)
+
+	tests := []struct {
+		name        string
+		state       *logSamplingState
+		messages    []logSamplingMessage
+		wantWritten []bool
+	}{
+		{
+			"inactive",
+			&logSamplingState{},
+			[]logSamplingMessage{info, info, info},
+			[]bool{true, true, true},
+		},
+		{
+			"default rule",
+			&logSamplingState{isActive: true, maxLevel: zerolog.InfoLevel, defaultRule: LogSamplingRule{Burst: 2}},
+			[]logSamplingMessage{info, info, info, other, other, other},
+			[]bool{true, true, false, true, true, false},
+		},
+		{
+			"message rule",
+			&logSamplingState{
+				isActive: true,
+				maxLevel: zerolog.InfoLevel,
+				rules:    map[string]LogSamplingRule{"info": {Burst: 1}},
+			},
+			[]logSamplingMessage{info, info, other, other},
+			[]bool{true, false, true, true},
+		},
+		{
+			"levels above sampling level",
+			&logSamplingState{isActive: true, maxLevel: zerolog.DebugLevel, defaultRule: LogSamplingRule{Burst: 1}},
+			[]logSamplingMessage{debug, debug, info, info, warning, warning},
+			[]bool{true, false, true, true, true, true},
+		},
+		{
+			"deduplication",
+			&logSamplingState{
+				isActive:    true,
+				maxLevel:    zerolog.InfoLevel,
+				deduplicate: true,
+				window:      time.Hour,
+				duplicates:  map[string]*logDuplicate{},
+			},
+			[]logSamplingMessage{info, info, other, errorA, errorA, errorB, warning, warning},
+			[]bool{true, false, true, true, false, true, true, true},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.state.buckets == nil {
+				tc.state.buckets = map[string]*logBucket{}
+			}
+
+			for i, m := range tc.messages {
+				_, isWritten := tc.state.filter(m.level, m.message, m.context)
+				if isWritten != tc.wantWritten[i] {
+					t.Errorf("message %d (%s): got written %t, want %t", i, m.message, isWritten, tc.wantWritten[i])
+				}
+			}
+		})
+	}
+}
+
+func TestLogSamplingDroppedCount(t *testing.T) {
+	s := &logSamplingState{
+		isActive:    true,
+		maxLevel:    zerolog.InfoLevel,
+		defaultRule: LogSamplingRule{Burst: 1, Rate: 1000},
+		buckets:     map[string]*logBucket{},
+	}
+
+	s.filter(zerolog.InfoLevel, "info", nil)
+	s.filter(zerolog.InfoLevel, "info", nil)
+	s.buckets["info"].last = time.Now().Add(-time.Second)
+
+	context, isWritten := s.filter(zerolog.InfoLevel, "info", nil)
+	if !isWritten {
+		t.Fatal("message not written after refill")
+	}
+	if dropped := context["sampled_dropped"]; dropped != 1 {
+		t.Errorf("got dropped count %v, want 1", dropped)
+	}
+}
+
+func TestLogSamplingFlush(t *testing.T) {
+	var buf bytes.Buffer
+	logger, level := log.Logger, zerolog.GlobalLevel()
+	defer func() { log.Logger = logger; zerolog.SetGlobalLevel(level) }()
+	log.Logger = zerolog.New(&buf)
+	zerolog.SetGlobalLevel(zerolog.TraceLevel)
+
+	s := &logSamplingState{
+		isActive:    true,
+		maxLevel:    zerolog.InfoLevel,
+		buckets:     map[string]*logBucket{},
+		deduplicate: true,
+		window:      time.Hour,
+		duplicates:  map[string]*logDuplicate{},
+	}
+	for i := 0; i < 3; i++ {
+		s.filter(zerolog.InfoLevel, "info", nil)
+	}
+	s.filter(zerolog.InfoLevel, "single", nil)
+
+	s.flush(false)
+	if buf.Len() > 0 {
+		t.Errorf("summary written before the window expired: %s", buf.String())
+	}
+
+	s.flush(true)
+	if !strings.Contains(buf.String(), "message repeated 2 times") || strings.Contains(buf.String(), "single") {
+		t.Errorf("unexpected summary: %s", buf.String())
+	}
+	if len(s.duplicates) > 0 {
+		t.Errorf("duplicates not removed on flush: %d", len(s.duplicates))
+	}
+}
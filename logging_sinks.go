@@ -0,0 +1,505 @@
+package uos
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+// LogSinkConfiguration specifies an additional log output.
+type LogSinkConfiguration struct {
+	// sink type: "console" (colored, stderr), "stdout" or "stderr" (JSON), "file" or "remote"
+	Type string `json:"type"`
+	// minimum log level written to this sink (default: all messages passing the global level)
+	Level string `json:"level"`
+	// message format for file and remote sinks: "json" (default) or "console"
+	Format string `json:"format"`
+
+	// log file path (type "file", relative to the base directory)
+	File string `json:"file"`
+	// rotate if the file exceeds the specified size in MB (0: no size based rotation)
+	MaxSizeMB int `json:"max_size_mb"`
+	// rotate after the specified interval, e.g. "24h" (empty: no time based rotation)
+	RotateInterval string `json:"rotate_interval"`
+	// number of rotated files to keep (0: keep all)
+	MaxBackups int `json:"max_backups"`
+	// remove rotated files older than the specified number of days (0: keep all)
+	MaxAgeDays int `json:"max_age_days"`
+
+	// network ("udp" or "tcp") and address ("host:port") of a syslog compatible receiver (type "remote")
+	Network string `json:"network"`
+	Address string `json:"address"`
+}
+
+var (
+	logSinkMutex sync.Mutex
+	logSinks     = []io.Writer{}
+)
+
+// setupLogSinks initializes the configured log sinks. Uses the legacy output (JSON to stdout or
+// console to stderr) if no sinks are configured.
+func setupLogSinks() {
+	logSinkMutex.Lock()
+	defer logSinkMutex.Unlock()
+
+	if len(Config.Logging.Sinks) == 0 {
+		var w io.Writer = os.Stderr
+		if Config.Logging.UseConsole {
+			w = zerolog.ConsoleWriter{Out: os.Stderr}
+		}
+
+		logSinks = []io.Writer{w}
+		updateLogOutput()
+		return
+	}
+
+	writers := []io.Writer{}
+	for _, sinkConfig := range Config.Logging.Sinks {
+		w, err := newLogSink(sinkConfig)
+		if err != nil {
+			panic(fmt.Sprintf("invalid log sink configuration (%s): %v", sinkConfig.Type, err))
+		}
+		writers = append(writers, w)
+	}
+
+	logSinks = writers
+	updateLogOutput()
+}
+
+func newLogSink(c LogSinkConfiguration) (*levelFilterWriter, error) {
+	level := zerolog.TraceLevel
+	if c.Level != "" {
+		var err error
+		level, err = parseLogLevel(c.Level)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var (
+		w      io.Writer
+		closer io.Closer
+	)
+	switch c.Type {
+	case "console":
+		w = zerolog.ConsoleWriter{Out: os.Stderr}
+	case "stdout":
+		w = os.Stdout
+	case "stderr":
+		w = os.Stderr
+	case "file":
+		if c.File == "" {
+			return nil, fmt.Errorf("log file not specified")
+		}
+
+		var interval time.Duration
+		if c.RotateInterval != "" {
+			var err error
+			interval, err = time.ParseDuration(c.RotateInterval)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		fileWriter := &rotatingFileWriter{
+			path:       basePath(c.File),
+			maxSize:    int64(c.MaxSizeMB) * 1024 * 1024,
+			interval:   interval,
+			maxBackups: c.MaxBackups,
+			maxAge:     time.Duration(c.MaxAgeDays) * 24 * time.Hour,
+		}
+		w, closer = fileWriter, fileWriter
+	case "remote":
+		if c.Address == "" {
+			return nil, fmt.Errorf("remote address not specified")
+		}
+
+		network := c.Network
+		if network == "" {
+			network = "udp"
+		}
+		remoteWriter := newRemoteLogWriter(network, c.Address)
+		w, closer = remoteWriter, remoteWriter
+	default:
+		return nil, fmt.Errorf("unknown log sink type")
+	}
+
+	if c.Format == "console" && (c.Type == "file" || c.Type == "remote") {
+		w = zerolog.ConsoleWriter{Out: w, NoColor: true}
+	}
+
+	return &levelFilterWriter{w: w, level: level, closer: closer}, nil
+}
+
+// RegisterLogSink adds an additional log output, e.g. a custom remote sink. Only messages with the specified level or
+// higher are written to the sink (empty level: all messages passing the global level).
+func RegisterLogSink(w io.Writer, level string) error {
+	minLevel := zerolog.TraceLevel
+	if level != "" {
+		var err error
+		minLevel, err = parseLogLevel(level)
+		if err != nil {
+			return err
+		}
+	}
+
+	logSinkMutex.Lock()
+	defer logSinkMutex.Unlock()
+
+	logSinks = append(logSinks, &levelFilterWriter{w: w, level: minLevel})
+	updateLogOutput()
+
+	return nil
+}
+
+// updateLogOutput sets the logger output to the current list of sinks. Mutex must be locked.
+func updateLogOutput() {
+	log.Logger = zerolog.New(zerolog.MultiLevelWriter(logSinks...)).With().Timestamp().Logger()
+}
+
+func cleanupLogSinks() {
+	logSinkMutex.Lock()
+	defer logSinkMutex.Unlock()
+
+	for _, w := range logSinks {
+		if lw, ok := w.(*levelFilterWriter); ok && lw.closer != nil {
+			lw.closer.Close()
+		}
+	}
+}
+
+// levelFilterWriter forwards messages with the specified minimum level to the wrapped writer.
+type levelFilterWriter struct {
+	w     io.Writer
+	level zerolog.Level
+
+	// resources opened for the sink (files, connections), closed on cleanup
+	closer io.Closer
+}
+
+func (w *levelFilterWriter) Write(p []byte) (int, error) {
+	return w.w.Write(p)
+}
+
+func (w *levelFilterWriter) WriteLevel(level zerolog.Level, p []byte) (int, error) {
+	if level < w.level {
+		return len(p), nil
+	}
+
+	if lw, ok := w.w.(zerolog.LevelWriter); ok {
+		return lw.WriteLevel(level, p)
+	}
+	return w.w.Write(p)
+}
+
+// rotatingFileWriter writes to a file that is rotated based on size and/or time.
+// Rotated files are renamed to "<path>.<timestamp>".
+type rotatingFileWriter struct {
+	mutex sync.Mutex
+
+	path       string
+	maxSize    int64
+	interval   time.Duration
+	maxBackups int
+	maxAge     time.Duration
+
+	file     *os.File
+	size     int64
+	openedAt time.Time
+}
+
+func (w *rotatingFileWriter) Write(p []byte) (int, error) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if w.file == nil {
+		err := w.open()
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	if w.isRotationRequired(int64(len(p))) {
+		err := w.rotate()
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := w.file.Write(p)
+	w.size += int64(n)
+
+	return n, err
+}
+
+func (w *rotatingFileWriter) Close() error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if w.file == nil {
+		return nil
+	}
+
+	err := w.file.Close()
+	w.file = nil
+
+	return err
+}
+
+func (w *rotatingFileWriter) isRotationRequired(writeSize int64) bool {
+	if w.maxSize > 0 && w.size > 0 && w.size+writeSize > w.maxSize {
+		return true
+	}
+	if w.interval > 0 && !time.Now().Before(w.openedAt.Truncate(w.interval).Add(w.interval)) {
+		return true
+	}
+
+	return false
+}
+
+func (w *rotatingFileWriter) open() error {
+	err := os.MkdirAll(filepath.Dir(w.path), 0755)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(w.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	w.file = file
+	w.size = info.Size()
+	w.openedAt = time.Now()
+	if w.size > 0 {
+		// continue existing file - rotation interval starts with file modification
+		w.openedAt = info.ModTime()
+	}
+
+	return nil
+}
+
+func (w *rotatingFileWriter) rotate() error {
+	err := w.file.Close()
+	if err != nil {
+		return err
+	}
+	w.file = nil
+
+	backupPath := fmt.Sprintf("%s.%s", w.path, time.Now().Format("20060102T150405.000000"))
+	err = os.Rename(w.path, backupPath)
+	if err != nil {
+		return err
+	}
+
+	w.removeBackups()
+
+	return w.open()
+}
+
+// removeBackups deletes rotated files according to the retention settings.
+func (w *rotatingFileWriter) removeBackups() {
+	if w.maxBackups <= 0 && w.maxAge <= 0 {
+		return
+	}
+
+	backups, err := filepath.Glob(w.path + ".*")
+	if err != nil {
+		return
+	}
+	// timestamp suffix: lexical order = chronological order (newest first)
+	sort.Sort(sort.Reverse(sort.StringSlice(backups)))
+
+	for i, backup := range backups {
+		isExpired := false
+		if w.maxAge > 0 {
+			info, err := os.Stat(backup)
+			isExpired = err == nil && time.Since(info.ModTime()) > w.maxAge
+		}
+
+		if (w.maxBackups > 0 && i >= w.maxBackups) || isExpired {
+			os.Remove(backup)
+		}
+	}
+}
+
+// remoteLogWriter sends log messages in syslog format (RFC 5424) to a remote receiver. Messages
+// are sent asynchronously (logging never waits for the receiver) - if the buffer is full or
+// the receiver is not reachable, messages are dropped.
+type remoteLogWriter struct {
+	network string
+	address string
+
+	hostname string
+	appName  string
+
+	// buffered messages - closed by Close
+	mutex    sync.RWMutex
+	messages chan []byte
+	isClosed bool
+	done     chan struct{}
+
+	// number of dropped messages since the last successful message
+	dropped uint64
+}
+
+const (
+	// number of buffered messages
+	remoteLogBufferSize = 1024
+	// timeouts of connection setup and sending
+	remoteLogTimeout = 2 * time.Second
+	// minimum interval between connection attempts
+	remoteLogRetryInterval = 10 * time.Second
+)
+
+func newRemoteLogWriter(network, address string) *remoteLogWriter {
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "-"
+	}
+
+	w := &remoteLogWriter{
+		network:  network,
+		address:  address,
+		hostname: hostname,
+		appName:  filepath.Base(os.Args[0]),
+		messages: make(chan []byte, remoteLogBufferSize),
+		done:     make(chan struct{}),
+	}
+	go w.send()
+
+	return w
+}
+
+func (w *remoteLogWriter) Write(p []byte) (int, error) {
+	return w.WriteLevel(zerolog.NoLevel, p)
+}
+
+func (w *remoteLogWriter) WriteLevel(level zerolog.Level, p []byte) (int, error) {
+	message := w.format(level, strings.TrimSpace(string(bytes.TrimRight(p, "\n"))))
+
+	w.mutex.RLock()
+	defer w.mutex.RUnlock()
+
+	if w.isClosed {
+		return 0, fmt.Errorf("remote log writer closed")
+	}
+
+	select {
+	case w.messages <- message:
+	default:
+		// buffer full
+		atomic.AddUint64(&w.dropped, 1)
+	}
+	return len(p), nil
+}
+
+// format returns the message in syslog format.
+func (w *remoteLogWriter) format(level zerolog.Level, message string) []byte {
+	// priority: facility "user" (1) * 8 + severity
+	return []byte(fmt.Sprintf(
+		"<%d>1 %s %s %s - - - %s\n",
+		8+syslogSeverity(level),
+		time.Now().Format(time.RFC3339),
+		w.hostname,
+		w.appName,
+		message,
+	))
+}
+
+// send writes the buffered messages to the receiver (until the writer is closed). Connection
+// attempts are limited to one per retry interval - messages are dropped in between.
+func (w *remoteLogWriter) send() {
+	defer close(w.done)
+
+	var (
+		conn    net.Conn
+		retryAt time.Time
+	)
+	for message := range w.messages {
+		if conn == nil {
+			if time.Now().Before(retryAt) {
+				atomic.AddUint64(&w.dropped, 1)
+				continue
+			}
+
+			var err error
+			conn, err = net.DialTimeout(w.network, w.address, remoteLogTimeout)
+			if err != nil {
+				conn = nil
+				retryAt = time.Now().Add(remoteLogRetryInterval)
+				atomic.AddUint64(&w.dropped, 1)
+				continue
+			}
+		}
+
+		if dropped := atomic.SwapUint64(&w.dropped, 0); dropped > 0 {
+			message = append(
+				w.format(zerolog.WarnLevel, fmt.Sprintf("%d log messages dropped", dropped)),
+				message...,
+			)
+		}
+
+		_ = conn.SetWriteDeadline(time.Now().Add(remoteLogTimeout))
+		_, err := conn.Write(message)
+		if err != nil {
+			// reconnect with next message
+			conn.Close()
+			conn = nil
+			atomic.AddUint64(&w.dropped, 1)
+		}
+	}
+
+	if conn != nil {
+		conn.Close()
+	}
+}
+
+// Close sends the buffered messages and closes the connection.
+func (w *remoteLogWriter) Close() error {
+	w.mutex.Lock()
+	if w.isClosed {
+		w.mutex.Unlock()
+		return nil
+	}
+	w.isClosed = true
+	close(w.messages)
+	w.mutex.Unlock()
+
+	<-w.done
+	return nil
+}
+
+func syslogSeverity(level zerolog.Level) int {
+	switch level {
+	case zerolog.PanicLevel, zerolog.FatalLevel:
+		return 2
+	case zerolog.ErrorLevel:
+		return 3
+	case zerolog.WarnLevel:
+		return 4
+	case zerolog.InfoLevel:
+		return 6
+	case zerolog.DebugLevel, zerolog.TraceLevel:
+		return 7
+	}
+
+	return 5
+}
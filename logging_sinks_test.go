@@ -0,0 +1,241 @@
+package uos
+
+import (
+	"bufio"
+	"bytes"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+func TestRotatingFileWriter(t *testing.T) {
+	tests := []struct {
+		name       string
+		maxSize    int64
+		maxBackups int
+		writes     int
+		wantFiles  int
+	}{
+		{"no rotation", 0, 0, 5, 1},
+		{"size based rotation", 25, 0, 5, 3},
+		{"rotation with backup limit", 25, 1, 5, 2},
+		{"message larger than limit", 5, 0, 3, 3},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "logs", "app.log")
+			w := &rotatingFileWriter{path: path, maxSize: tc.maxSize, maxBackups: tc.maxBackups}
+			defer w.Close()
+
+			for i := 0; i < tc.writes; i++ {
+				n, err := w.Write([]byte("message 0\n"))
+				if err != nil || n != 10 {
+					t.Fatalf("write failed: %d, %v", n, err)
+				}
+				// distinct backup timestamps
+				time.Sleep(time.Millisecond)
+			}
+
+			files, err := filepath.Glob(path + "*")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(files) != tc.wantFiles {
+				t.Errorf("got %d files %v, want %d", len(files), files, tc.wantFiles)
+			}
+			if _, err := os.Stat(path); err != nil {
+				t.Errorf("current log file missing: %v", err)
+			}
+		})
+	}
+}
+
+func TestRotatingFileWriterContinuesExistingFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	err := os.WriteFile(path, []byte("existing 0\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := &rotatingFileWriter{path: path, maxSize: 15}
+	_, err = w.Write([]byte("message 0\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Close()
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != "message 0\n" {
+		t.Errorf("got current file content %q - existing size not considered", content)
+	}
+}
+
+func TestNewLogSinkFilePath(t *testing.T) {
+	baseDir := Config.BaseDir
+	defer func() { Config.BaseDir = baseDir }()
+	Config.BaseDir = t.TempDir()
+
+	tests := []struct {
+		file     string
+		wantPath string
+	}{
+		{"app.log", filepath.Join(Config.BaseDir, "app.log")},
+		{"logs/app.log", filepath.Join(Config.BaseDir, "logs", "app.log")},
+		{"/var/log/app.log", "/var/log/app.log"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.file, func(t *testing.T) {
+			sink, err := newLogSink(LogSinkConfiguration{Type: "file", File: tc.file})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if path := sink.w.(*rotatingFileWriter).path; path != tc.wantPath {
+				t.Errorf("got path %q, want %q", path, tc.wantPath)
+			}
+		})
+	}
+}
+
+func TestLevelFilterWriter(t *testing.T) {
+	tests := []struct {
+		name      string
+		level     zerolog.Level
+		message   zerolog.Level
+		isWritten bool
+	}{
+		{"below minimum", zerolog.WarnLevel, zerolog.InfoLevel, false},
+		{"minimum level", zerolog.WarnLevel, zerolog.WarnLevel, true},
+		{"above minimum", zerolog.WarnLevel, zerolog.ErrorLevel, true},
+		{"all levels", zerolog.TraceLevel, zerolog.TraceLevel, true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			w := &levelFilterWriter{w: &buf, level: tc.level}
+
+			n, err := w.WriteLevel(tc.message, []byte("message"))
+			if err != nil || n != len("message") {
+				t.Fatalf("write failed: %d, %v", n, err)
+			}
+			if isWritten := buf.Len() > 0; isWritten != tc.isWritten {
+				t.Errorf("got written %t, want %t", isWritten, tc.isWritten)
+			}
+		})
+	}
+}
+
+func TestNewLogSinkErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		config LogSinkConfiguration
+	}{
+		{"unknown type", LogSinkConfiguration{Type: "unknown"}},
+		{"invalid level", LogSinkConfiguration{Type: "stdout", Level: "verbose"}},
+		{"file without path", LogSinkConfiguration{Type: "file"}},
+		{"invalid rotation interval", LogSinkConfiguration{Type: "file", File: "app.log", RotateInterval: "daily"}},
+		{"remote without address", LogSinkConfiguration{Type: "remote"}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := newLogSink(tc.config)
+			if err == nil {
+				t.Error("expected error")
+			}
+		})
+	}
+}
+
+func TestRemoteLogWriter(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	received := make(chan []string)
+	go func() {
+		lines := []string{}
+		defer func() { received <- lines }()
+
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		scanner := bufio.NewScanner(conn)
+		for scanner.Scan() {
+			lines = append(lines, scanner.Text())
+		}
+	}()
+
+	messages := []struct {
+		level      zerolog.Level
+		message    string
+		wantPrefix string
+	}{
+		{zerolog.ErrorLevel, `{"message":"error"}`, "<11>1 "},
+		{zerolog.WarnLevel, `{"message":"warning"}`, "<12>1 "},
+		{zerolog.InfoLevel, `{"message":"info"}`, "<14>1 "},
+		{zerolog.DebugLevel, `{"message":"debug"}`, "<15>1 "},
+		{zerolog.NoLevel, `{"message":"no level"}`, "<13>1 "},
+	}
+
+	w := newRemoteLogWriter("tcp", listener.Addr().String())
+	for _, m := range messages {
+		_, err := w.WriteLevel(m.level, []byte(m.message+"\n"))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	w.Close()
+
+	if _, err := w.Write([]byte("closed")); err == nil {
+		t.Error("expected error writing to closed writer")
+	}
+
+	lines := <-received
+	if len(lines) != len(messages) {
+		t.Fatalf("got %d messages %v, want %d", len(lines), lines, len(messages))
+	}
+	for i, m := range messages {
+		if !strings.HasPrefix(lines[i], m.wantPrefix) || !strings.HasSuffix(lines[i], " - - - "+m.message) {
+			t.Errorf("got message %q, want prefix %q and message %q", lines[i], m.wantPrefix, m.message)
+		}
+	}
+}
+
+func TestRemoteLogWriterUnreachable(t *testing.T) {
+	// reserve a port without receiver
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := listener.Addr().String()
+	listener.Close()
+
+	w := newRemoteLogWriter("tcp", address)
+	for i := 0; i < 3; i++ {
+		_, err := w.WriteLevel(zerolog.InfoLevel, []byte("message\n"))
+		if err != nil {
+			t.Fatalf("write must not fail if the receiver is unreachable: %v", err)
+		}
+	}
+	w.Close()
+
+	if dropped := w.dropped; dropped != 3 {
+		t.Errorf("got %d dropped messages, want 3", dropped)
+	}
+}
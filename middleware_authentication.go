@@ -93,7 +93,7 @@ func mwAuthentication(next http.Handler, doAuthRedirect bool) http.Handler {
 
 			var user AppUser
 			if session.UserID > 0 {
-				err = DB.First(&user, session.UserID).Error
+				err = DBContext(r).First(&user, session.UserID).Error
 				if errors.Is(err, gorm.ErrRecordNotFound) {
 					// invalid session (user not available) -> continue without authentification
 					forwardWithoutSession()
@@ -107,6 +107,7 @@ func mwAuthentication(next http.Handler, doAuthRedirect bool) http.Handler {
 
 				user.csrfToken = session.CSRFToken
 			}
+			AddLogFields(r, LogContext{"user": user.ID})
 
 			ctx := context.WithValue(r.Context(), ctxAppUser, user)
 			next.ServeHTTP(w, r.WithContext(ctx))
@@ -6,17 +6,30 @@ import (
 	"strings"
 )
 
+// multipart requests: maximum size of file parts kept in memory (larger parts: temporary files)
+const multipartMaxMemory = 8 << 20
+
 const (
 	ctxRequestID      string = "ctxRequestID"
 	ctxClientLanguage string = "ctxClientLanguage"
+	ctxLogFields      string = "ctxLogFields"
 )
 
 func mwContext(next http.Handler) http.Handler {
 	return http.HandlerFunc(
 		func(w http.ResponseWriter, r *http.Request) {
 			// setup context for request handling
-			// .. create random request ID
-			ctx := context.WithValue(r.Context(), ctxRequestID, randomString(8))
+			// .. use request ID provided by a trusted proxy (e.g. for log level overrides) or create
+			//    random request ID
+			requestID := r.Header.Get("X-Request-ID")
+			if !isTrustedProxy(remoteHost(r)) || !isValidRequestID(requestID) {
+				requestID = randomString(8)
+			}
+			w.Header().Set("X-Request-ID", requestID)
+			ctx := context.WithValue(r.Context(), ctxRequestID, requestID)
+
+			// .. request-scoped log fields (route, client IP, ...)
+			ctx = context.WithValue(ctx, ctxLogFields, newRequestLogFields(r))
 
 			// .. get client language
 			language := strings.Split(r.Header.Get("Accept-Language"), ",")[0]
@@ -31,8 +44,16 @@ func mwContext(next http.Handler) http.Handler {
 
 			r = r.WithContext(ctx)
 
-			//  parse URL form data (might be empty)
-			err = r.ParseForm()
+			//  parse URL form data (might be empty) - including uploaded files
+			if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+				r.Body = http.MaxBytesReader(w, r.Body, Config.Files.maxRequestSize())
+				err = r.ParseMultipartForm(multipartMaxMemory)
+				if r.MultipartForm != nil {
+					defer r.MultipartForm.RemoveAll()
+				}
+			} else {
+				err = r.ParseForm()
+			}
 			if err != nil {
 				Log.WarnErrorR(r, "could not parse form", err)
 				RespondBadRequest(w)
@@ -44,3 +65,19 @@ func mwContext(next http.Handler) http.Handler {
 		},
 	)
 }
+
+// isValidRequestID checks a provided request ID: at most 64 characters (letters, digits, "-").
+func isValidRequestID(id string) bool {
+	if len(id) == 0 || len(id) > 64 {
+		return false
+	}
+
+	for _, c := range id {
+		isValid := (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9') || c == '-'
+		if !isValid {
+			return false
+		}
+	}
+
+	return true
+}
@@ -9,17 +9,30 @@ type loggingResponseWriter struct {
 	http.ResponseWriter
 
 	statusCode int
+	isWritten  bool
 }
 
 func newLoggingResponseWriter(w http.ResponseWriter) *loggingResponseWriter {
-	return &loggingResponseWriter{w, http.StatusOK}
+	return &loggingResponseWriter{w, http.StatusOK, false}
 }
 
 func (w *loggingResponseWriter) WriteHeader(code int) {
 	w.statusCode = code
+	w.isWritten = true
 	w.ResponseWriter.WriteHeader(code)
 }
 
+func (w *loggingResponseWriter) Write(b []byte) (int, error) {
+	w.isWritten = true
+	return w.ResponseWriter.Write(b)
+}
+
+// isResponseWritten checks whether a response (header or body) was already written.
+func isResponseWritten(w http.ResponseWriter) bool {
+	lrw, ok := w.(*loggingResponseWriter)
+	return ok && lrw.isWritten
+}
+
 func mwLogging(next http.Handler) http.Handler {
 	return http.HandlerFunc(
 		func(w http.ResponseWriter, r *http.Request) {
@@ -28,6 +41,9 @@ func mwLogging(next http.Handler) http.Handler {
 				lrw       = newLoggingResponseWriter(w)
 			)
 
+			r, span := startRequestSpan(r)
+			defer endRequestSpanOnPanic(span)
+
 			Log.InfoContextR(
 				r,
 				"received request",
@@ -42,6 +58,7 @@ func mwLogging(next http.Handler) http.Handler {
 
 			next.ServeHTTP(lrw, r)
 			duration := time.Since(startTime)
+			endRequestSpan(span, lrw.statusCode)
 
 			Metrics.GaugeDec(mRequestActive)
 			Metrics.CounterIncValueCondition(mRequestDuration, duration.Milliseconds(), lrw.statusCode < 500)
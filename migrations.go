@@ -0,0 +1,297 @@
+package uos
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Migration describes a versioned database migration. Migrations are executed in ascending
+// version order - each inside a separate transaction.
+type Migration struct {
+	// unique version, e.g. 2023050101
+	Version uint64
+	// short description
+	Description string
+
+	// migration function - either Up or SQL must be specified
+	Up func(tx *gorm.DB) error
+	// SQL statements
+	SQL string
+}
+
+// MigrationStatus describes a registered migration and whether it is already applied.
+type MigrationStatus struct {
+	Migration
+
+	IsApplied bool
+	AppliedAt time.Time
+}
+
+// schemaMigration records an applied migration.
+type schemaMigration struct {
+	Version     uint64 `gorm:"primaryKey;autoIncrement:false"`
+	Description string
+	AppliedAt   time.Time
+}
+
+func (schemaMigration) TableName() string {
+	return "internal_schema_migrations"
+}
+
+var migrations = map[uint64]Migration{}
+
+// RegisterMigrations adds migrations. Registered migrations are executed during ComponentSetup
+// (before application models are auto-migrated) or using MigrationCommand. Migrations adapt
+// existing databases: on an empty database all migrations registered before ComponentSetup are
+// marked as applied (baseline) - the schema is created by auto-migration (RegisterDBModels).
+// Panics if a version is registered twice or a migration has no Up function or SQL.
+func RegisterMigrations(list ...Migration) {
+	for _, m := range list {
+		if _, ok := migrations[m.Version]; ok {
+			panic(fmt.Sprintf("migration %d registered twice", m.Version))
+		}
+		if m.Up == nil && strings.TrimSpace(m.SQL) == "" {
+			panic(fmt.Sprintf("migration %d has no Up function or SQL", m.Version))
+		}
+
+		migrations[m.Version] = m
+	}
+}
+
+// RegisterMigrationFiles adds all SQL migrations in the given directory of the file system.
+// File names must have the format "<version>_<description>.sql", e.g. "2023050101_add_index.sql".
+func RegisterMigrationFiles(fsys fs.FS, dir string) error {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".sql") {
+			continue
+		}
+
+		name := strings.TrimSuffix(entry.Name(), ".sql")
+		parts := strings.SplitN(name, "_", 2)
+
+		version, err := strconv.ParseUint(parts[0], 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid migration file name '%s': %w", entry.Name(), err)
+		}
+
+		content, err := fs.ReadFile(fsys, path.Join(dir, entry.Name()))
+		if err != nil {
+			return err
+		}
+
+		description := name
+		if len(parts) == 2 {
+			description = strings.ReplaceAll(parts[1], "_", " ")
+		}
+
+		RegisterMigrations(Migration{
+			Version:     version,
+			Description: description,
+			SQL:         string(content),
+		})
+	}
+
+	return nil
+}
+
+// setupMigrations creates the migrations table and registers the migration files. All
+// migrations are marked as applied for a new (empty) database.
+func setupMigrations(isEmptyDatabase bool) {
+	err := DB.AutoMigrate(&schemaMigration{})
+	if err != nil {
+		Log.PanicError("could not create migrations table", err)
+	}
+
+	if Config.Database.Migrations != "" {
+		err = RegisterMigrationFiles(os.DirFS(basePath(Config.Database.Migrations)), ".")
+		if err != nil {
+			Log.PanicError("could not read migration files", err)
+		}
+	}
+
+	if isEmptyDatabase {
+		err = baselineMigrations()
+		if err != nil {
+			Log.PanicError("could not mark migrations as applied", err)
+		}
+	}
+}
+
+// baselineMigrations marks all registered migrations as applied.
+func baselineMigrations() error {
+	now := time.Now()
+	for _, m := range migrations {
+		err := DB.Create(&schemaMigration{
+			Version:     m.Version,
+			Description: m.Description,
+			AppliedAt:   now,
+		}).Error
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(migrations) > 0 {
+		Log.InfoContext("new database - migrations marked as applied", LogContext{"count": len(migrations)})
+	}
+	return nil
+}
+
+// GetMigrationStatus returns all registered migrations (ordered by version) including
+// information whether they are already applied.
+func GetMigrationStatus() ([]MigrationStatus, error) {
+	var applied []schemaMigration
+	err := DB.Find(&applied).Error
+	if err != nil {
+		return nil, err
+	}
+
+	appliedAt := map[uint64]time.Time{}
+	for _, a := range applied {
+		appliedAt[a.Version] = a.AppliedAt
+	}
+
+	result := []MigrationStatus{}
+	for _, m := range migrations {
+		t, ok := appliedAt[m.Version]
+		result = append(result, MigrationStatus{Migration: m, IsApplied: ok, AppliedAt: t})
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Version < result[j].Version })
+
+	return result, nil
+}
+
+// RunMigrations executes all pending migrations. Stops at the first failing migration.
+// In dry-run mode, pending migrations are only determined - not executed.
+// Returns the list of executed (or pending, in dry-run mode) migrations.
+func RunMigrations(dryRun bool) ([]Migration, error) {
+	status, err := GetMigrationStatus()
+	if err != nil {
+		return nil, err
+	}
+
+	pending := []Migration{}
+	for _, s := range status {
+		if !s.IsApplied {
+			pending = append(pending, s.Migration)
+		}
+	}
+
+	if dryRun {
+		for _, m := range pending {
+			Log.InfoContext(
+				"pending migration (dry-run)",
+				LogContext{"version": m.Version, "description": m.Description},
+			)
+		}
+		return pending, nil
+	}
+
+	executed := []Migration{}
+	for _, m := range pending {
+		Log.InfoContext(
+			"execute migration",
+			LogContext{"version": m.Version, "description": m.Description},
+		)
+
+		err := DB.Transaction(func(tx *gorm.DB) error {
+			var err error
+			if m.Up != nil {
+				err = m.Up(tx)
+			} else {
+				err = tx.Exec(m.SQL).Error
+			}
+			if err != nil {
+				return err
+			}
+
+			return tx.Create(&schemaMigration{
+				Version:     m.Version,
+				Description: m.Description,
+				AppliedAt:   time.Now(),
+			}).Error
+		})
+		if err != nil {
+			return executed, fmt.Errorf("migration %d failed: %w", m.Version, err)
+		}
+
+		executed = append(executed, m)
+	}
+
+	return executed, nil
+}
+
+func runMigrationsOnSetup() {
+	if Config.Database.SkipMigrations {
+		Log.Info("automatic migrations disabled")
+		return
+	}
+
+	executed, err := RunMigrations(false)
+	if err != nil {
+		Log.PanicError("database migration failed", err)
+	}
+
+	Log.InfoContext("database migrations done", LogContext{"executed": len(executed)})
+}
+
+// MigrationCommand provides a command line interface for database migrations. Reads the
+// configuration file and opens the database without running migrations automatically (and
+// without starting background tasks, e.g. scheduled backups).
+// Commands: "status" (default), "up", "dry-run". Output is written to stdout.
+//
+// Usage (e.g. in main): if os.Args[1] == "migrate" { err := uos.MigrationCommand("app_config.json", os.Args[2:]) }
+func MigrationCommand(configFile string, args []string) error {
+	command := "status"
+	if len(args) > 0 {
+		command = args[0]
+	}
+
+	err := readConfiguration(configFile)
+	if err != nil {
+		return err
+	}
+	setupLogging()
+	openDataAccess()
+	defer ComponentCleanup()
+
+	switch command {
+	case "status":
+		status, err := GetMigrationStatus()
+		if err != nil {
+			return err
+		}
+		for _, s := range status {
+			state := "pending"
+			if s.IsApplied {
+				state = "applied " + s.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Printf("%d\t%s\t%s\n", s.Version, state, s.Description)
+		}
+	case "up", "dry-run":
+		list, err := RunMigrations(command == "dry-run")
+		for _, m := range list {
+			fmt.Printf("%d\t%s\n", m.Version, m.Description)
+		}
+		if err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unknown migration command: '%s'", command)
+	}
+
+	return nil
+}
@@ -5,6 +5,7 @@ import (
 	"net/http"
 	"net/http/pprof"
 	"sync"
+	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
@@ -39,6 +40,11 @@ func setupMonitoring() {
 			metricsMux := http.NewServeMux()
 
 			metricsMux.Handle("/metrics", promhttp.Handler())
+			metricsMux.HandleFunc("/healthz", healthHandler)
+			metricsMux.HandleFunc("/readyz", readinessHandler)
+			if Config.Monitoring.AdminToken != "" {
+				metricsMux.HandleFunc("/admin/loglevel", logLevelAdminHandler)
+			}
 
 			err := http.ListenAndServe(fmt.Sprintf(":%d", Config.Monitoring.PortMetrics), metricsMux)
 			if err != nil {
@@ -56,6 +62,9 @@ type metricsRegistry struct {
 
 	counter map[int]prometheus.Counter
 	gauge   map[int]prometheus.Gauge
+
+	counterVec   map[int]*prometheus.CounterVec
+	histogramVec map[int]*prometheus.HistogramVec
 }
 
 // metric IDs - will be assigned during registration
@@ -72,6 +81,10 @@ var (
 	mLogMessageWarning int
 	mLogMessageError   int
 	mLogMessagePanic   int
+
+	mComponentRequests int
+	mComponentEvents   int
+	mComponentDuration int
 )
 
 func newMetricsRegistry() *metricsRegistry {
@@ -80,6 +93,9 @@ func newMetricsRegistry() *metricsRegistry {
 
 		counter: map[int]prometheus.Counter{},
 		gauge:   map[int]prometheus.Gauge{},
+
+		counterVec:   map[int]*prometheus.CounterVec{},
+		histogramVec: map[int]*prometheus.HistogramVec{},
 	}
 
 	// register standard metrics
@@ -127,6 +143,22 @@ func newMetricsRegistry() *metricsRegistry {
 		"Number of recorded PANIC/FATAL log messages.",
 	)
 
+	mComponentRequests = registry.RegisterCounterVec(
+		"app_component_requests_count",
+		"Number of handled requests per component (form, table, fragment, dialog) and name.",
+		"component", "name",
+	)
+	mComponentEvents = registry.RegisterCounterVec(
+		"app_component_events_count",
+		"Number of component events (e.g. save, validation_failed, delete, render_error).",
+		"component", "name", "event",
+	)
+	mComponentDuration = registry.RegisterHistogramVec(
+		"app_component_duration_seconds",
+		"Duration of component processing steps (e.g. total, load_data, count, parse, execute).",
+		"component", "name", "step",
+	)
+
 	return &registry
 }
 
@@ -254,3 +286,108 @@ func (m *metricsRegistry) GaugeDec(id int) {
 		g.Dec()
 	}
 }
+
+// RegisterCounterVec creates a new counter with the given name and label names. Does nothing if the
+// name is already registered. Returns metric ID - used for actual metric operation.
+func (m *metricsRegistry) RegisterCounterVec(name, help string, labels ...string) int {
+	metricID := m.assignMetricID(name)
+	if metricID == 0 {
+		// already registered
+		return 0
+	}
+
+	// create
+	m.counterVec[metricID] = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: name,
+			Help: help,
+		},
+		labels,
+	)
+
+	return metricID
+}
+
+// CounterVecInc increases the counter value for the specified label values.
+func (m *metricsRegistry) CounterVecInc(id int, labelValues ...string) {
+	if m == nil {
+		return
+	}
+	if c, ok := m.counterVec[id]; ok {
+		c.WithLabelValues(labelValues...).Inc()
+	}
+}
+
+// RegisterHistogramVec creates a new histogram with the given name and label names. Uses the default
+// Prometheus buckets. Does nothing if the name is already registered. The name should end with the
+// unit of the value (e.g. '_seconds'). Returns metric ID - used for actual metric operation.
+func (m *metricsRegistry) RegisterHistogramVec(name, help string, labels ...string) int {
+	metricID := m.assignMetricID(name)
+	if metricID == 0 {
+		// already registered
+		return 0
+	}
+
+	// create
+	m.histogramVec[metricID] = promauto.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    name,
+			Help:    help,
+			Buckets: prometheus.DefBuckets,
+		},
+		labels,
+	)
+
+	return metricID
+}
+
+// HistogramVecObserve adds the specified value to the histogram for the given label values.
+func (m *metricsRegistry) HistogramVecObserve(id int, value float64, labelValues ...string) {
+	if m == nil {
+		return
+	}
+	if h, ok := m.histogramVec[id]; ok {
+		h.WithLabelValues(labelValues...).Observe(value)
+	}
+}
+
+// componentTimer measures the duration of a single processing step of a named component.
+// Usage: defer startComponentTimer("table", name, "load_data").stop()
+type componentTimer struct {
+	component string
+	name      string
+	step      string
+
+	start time.Time
+}
+
+func startComponentTimer(component, name, step string) componentTimer {
+	return componentTimer{
+		component: component,
+		name:      name,
+		step:      step,
+		start:     time.Now(),
+	}
+}
+
+func (t componentTimer) stop() {
+	Metrics.HistogramVecObserve(
+		mComponentDuration,
+		time.Since(t.start).Seconds(),
+		t.component, t.name, t.step,
+	)
+}
+
+// unknownComponentName is the metrics label of requested names without registered component
+// or template (requested URLs must not create arbitrary label values).
+const unknownComponentName = "unknown"
+
+// componentRequest counts a handled request for the specified component.
+func componentRequest(component, name string) {
+	Metrics.CounterVecInc(mComponentRequests, component, name)
+}
+
+// componentEvent counts an event (e.g. "save" or "render_error") for the specified component.
+func componentEvent(component, name, event string) {
+	Metrics.CounterVecInc(mComponentEvents, component, name, event)
+}
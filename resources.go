@@ -92,8 +92,13 @@ func ResourceHandler(resource ResourceSpec) AppRequestHandlerMapping {
 				return
 			}
 
-			if r.Form.Get("format") != "" {
-				templateName = fmt.Sprintf("%s_%s", templateName, r.Form.Get("format"))
+			if format := r.Form.Get("format"); format != "" {
+				// format selects a template variant - only simple names are valid
+				if !isSimpleName(format) {
+					RespondNotFound(w)
+					return
+				}
+				templateName = fmt.Sprintf("%s_%s", templateName, format)
 			}
 
 			Log.TraceContextR(r, "resource context", LogContext{"context": context})
@@ -112,7 +117,7 @@ func renderResource(w http.ResponseWriter, r *http.Request, name string, context
 	}
 
 	// render resource
-	err := tmpl.ExecuteTemplate(w, name, context)
+	err := executeTemplate(w, tmpl, name, context, resourceTemplateName)
 	if err != nil {
 		Log.ErrorContextR(
 			r, "could not execute resource template",
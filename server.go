@@ -1,22 +1,69 @@
 package uos
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 )
 
 var appMux = http.NewServeMux()
 
 // StartApp starts the web application server.
-// Starts handling requests at the configured port. Blocks.
-// Panics if anything fails.
+// Starts handling requests at the configured port. Blocks until the server is shut down
+// gracefully (SIGINT or SIGTERM). Panics if anything fails.
 func StartApp() {
 	Log.InfoContext("start listening", LogContext{"port": Config.Port})
 
 	setupSitemapHandler()
 
-	err := http.ListenAndServe(fmt.Sprintf(":%d", Config.Port), appMux)
-	if err != nil {
+	server := &http.Server{
+		Addr:    fmt.Sprintf(":%d", Config.Port),
+		Handler: appMux,
+	}
+
+	shutdownDone := make(chan struct{})
+	go func() {
+		defer close(shutdownDone)
+
+		signals := make(chan os.Signal, 1)
+		signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+		sig := <-signals
+
+		// report "not ready" - no new requests should be routed to this instance
+		Log.InfoContext("shutdown requested", LogContext{"signal": sig.String()})
+		setAppReady(false)
+
+		if Config.ShutdownDelay > 0 {
+			// give load balancers time to notice the readiness change
+			time.Sleep(time.Duration(Config.ShutdownDelay) * time.Second)
+		}
+
+		timeout := Config.ShutdownTimeout
+		if timeout <= 0 {
+			timeout = 10
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Duration(timeout)*time.Second)
+		defer cancel()
+
+		err := server.Shutdown(ctx)
+		if err != nil {
+			Log.ErrorObj("graceful shutdown failed", err)
+		}
+	}()
+
+	setAppReady(true)
+
+	err := server.ListenAndServe()
+	if !errors.Is(err, http.ErrServerClosed) {
 		Log.PanicError("application error", err)
 	}
+
+	<-shutdownDone
+	Log.Info("application server stopped")
 }
@@ -25,9 +25,12 @@ func ComponentSetup(configFilePath ...string) {
 
 	setupLogging()
 	setupMonitoring()
+	setupTracing()
 	setupDataAccess()
+	setupFileStorage()
 	setupAuthentication()
 	setupInternationalization()
+	setupHealthChecks()
 
 	rand.Seed(time.Now().UnixNano())
 
@@ -39,5 +42,10 @@ func ComponentSetup(configFilePath ...string) {
 func ComponentCleanup() {
 	Log.Info("framework cleanup")
 
+	setAppReady(false)
+
+	cleanupFileStorage()
 	cleanupDataAccess()
+	cleanupTracing()
+	cleanupLogging()
 }
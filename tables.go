@@ -1,15 +1,18 @@
 package uos
 
 import (
+	"context"
 	"fmt"
 	"math"
 	"math/rand"
 	"net/http"
 	"net/url"
+	"sort"
 	"strconv"
 	"strings"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 // TableData represents a table with rows and columns.
@@ -45,12 +48,47 @@ type TableDisplay interface {
 	DisplaySettings() TableDisplayProperties
 }
 
+// TableCount must be implemented by a web application table to customize the number of
+// entries, e.g. if the table data is filtered. Default: number of model entries.
+type TableCount interface {
+	// Count returns the overall number of table entries for the specified configuration.
+	Count(TableConfiguration) (int64, error)
+}
+
 // TableDelete must be implemented by a web application form to support DELETE requests.
+// Deleting is not executed inside the request transaction (see TableSpecDeleteContext).
 type TableSpecDelete interface {
 	// Delete removes the specified items from the database.
 	Delete(ids []uint) (*ResponseAction, error)
 }
 
+// TableSpecDeleteContext can be implemented instead of TableSpecDelete to access the request
+// context while deleting, e.g. to use the request transaction (DBFromContext).
+type TableSpecDeleteContext interface {
+	// DeleteContext removes the specified items from the database.
+	DeleteContext(ctx context.Context, ids []uint) (*ResponseAction, error)
+}
+
+type tableDeleteAdapter struct {
+	TableSpecDelete
+}
+
+func (a tableDeleteAdapter) DeleteContext(_ context.Context, ids []uint) (*ResponseAction, error) {
+	return a.Delete(ids)
+}
+
+func (a tableDeleteAdapter) withoutTransaction() {}
+
+func getTableDelete(spec TableSpec) (TableSpecDeleteContext, bool) {
+	if t, ok := spec.(TableSpecDeleteContext); ok {
+		return t, true
+	}
+	if t, ok := spec.(TableSpecDelete); ok {
+		return tableDeleteAdapter{t}, true
+	}
+	return nil, false
+}
+
 // TableAction describes a function that can be triggered for a table, e.g. item deletion.
 type TableAction struct {
 	// (LineAwesome) icon of the action
@@ -142,13 +180,20 @@ func getTableHandlerFunc(tables []TableSpec) AppRequestHandler {
 			return
 		}
 
+		addComponentLogFields(r, "table", tableName)
+		componentRequest("table", tableName)
+		defer startComponentTimer("table", tableName, "total").stop()
+
+		r, span := startSpan(r, "table "+tableName)
+		defer span.End()
+
 		// process request
 		switch r.Method {
 		case http.MethodGet:
 			renderTable(w, r, tableSpec, r.Form)
 		case http.MethodPost:
 			// does the table support DELETE method?
-			tableDelete, ok := tableSpec.(TableSpecDelete)
+			tableDelete, ok := getTableDelete(tableSpec)
 			if !ok {
 				RespondNotImplemented(w)
 				return
@@ -172,11 +217,19 @@ func getTableHandlerFunc(tables []TableSpec) AppRequestHandler {
 			}
 
 			// forward to table handler
-			action, err := tableDelete.Delete(ids)
+			action, err := inRequestTransaction(
+				r, tableDelete,
+				func(r *http.Request) (*ResponseAction, error) {
+					return tableDelete.DeleteContext(r.Context(), ids)
+				},
+			)
 			if err != nil {
+				componentEvent("table", tableName, "delete_error")
 				handleFormError(w, r, "could not delete table items", err)
 				return
 			}
+			componentEvent("table", tableName, "delete")
+			auditLog(r, AuditRecord{Action: "delete", Target: "table:" + tableName, TargetID: joinIDs(ids)})
 
 			handleResponseAction(w, r, action)
 		default:
@@ -200,24 +253,45 @@ type TableConfiguration struct {
 	Page int
 	// number of rows to return
 	Rows int
+
+	// filter values by column (URL parameters "f_<column>")
+	Filter map[string]string
+
+	// request context (used for DB queries)
+	ctx context.Context
 }
 
+// Context returns the context of the table request.
+func (c TableConfiguration) Context() context.Context {
+	if c.ctx == nil {
+		return context.Background()
+	}
+	return c.ctx
+}
+
+// DBQuery returns a query for the configured columns, sort order and page.
 func (c TableConfiguration) DBQuery() *gorm.DB {
-	dbQuery := DB
+	return c.ApplyQuery(DBFromContext(c.Context()))
+}
+
+// ApplyQuery extends the given query with the configured columns, sort order and page.
+func (c TableConfiguration) ApplyQuery(dbQuery *gorm.DB) *gorm.DB {
 
 	if len(c.Columns) > 0 {
 		dbQuery = dbQuery.Select(append([]string{"id"}, c.Columns...))
 	}
+	// column names are quoted according to the database dialect
 	if c.SortColumn != "" {
 		dbQuery = dbQuery.Order(
-			strings.TrimSpace(
-				strings.Join([]string{c.SortColumn, c.SortMode}, " "),
-			),
+			clause.OrderByColumn{
+				Column: clause.Column{Name: c.SortColumn},
+				Desc:   c.SortMode == "DESC",
+			},
 		)
 	}
 
 	// additionally order by id to get a deterministic sequence
-	dbQuery = dbQuery.Order("id")
+	dbQuery = dbQuery.Order(clause.OrderByColumn{Column: clause.Column{Name: "id"}})
 
 	return dbQuery.Limit(c.Rows).Offset((c.Page - 1) * c.Rows)
 }
@@ -227,7 +301,7 @@ func (c TableConfiguration) LoadTable(dest interface{}) error {
 	return c.DBQuery().Find(dest).Error
 }
 
-func newTableConfiguration(t TableSpec, form url.Values) TableConfiguration {
+func newTableConfiguration(r *http.Request, t TableSpec, form url.Values) TableConfiguration {
 	var (
 		columns = form.Get("cols")
 
@@ -249,6 +323,16 @@ func newTableConfiguration(t TableSpec, form url.Values) TableConfiguration {
 		SortMode:   sortMode,
 		Page:       stringToInt(form.Get("page"), 1),
 		Rows:       stringToInt(form.Get("rows"), 10),
+
+		Filter: map[string]string{},
+
+		ctx: r.Context(),
+	}
+
+	for key := range form {
+		if strings.HasPrefix(key, "f_") && len(key) > 2 {
+			config.Filter[key[2:]] = form.Get(key)
+		}
 	}
 
 	if columns == "" {
@@ -258,6 +342,12 @@ func newTableConfiguration(t TableSpec, form url.Values) TableConfiguration {
 	return config
 }
 
+// tableConfigurationValidator can be implemented by table specs to restrict the configuration
+// (e.g. the accessible columns).
+type tableConfigurationValidator interface {
+	isValidConfiguration(c TableConfiguration) bool
+}
+
 func (c TableConfiguration) isValid() bool {
 	// at least one column must exist
 	if len(c.Columns) == 0 {
@@ -355,12 +445,15 @@ type tableRenderContext struct {
 	PageCount int
 }
 
-func newTableRenderContext(t TableSpec, form url.Values) (tableRenderContext, error) {
+func newTableRenderContext(r *http.Request, t TableSpec, form url.Values) (tableRenderContext, error) {
 	// get configuration from URL parameter
-	config := newTableConfiguration(t, form)
+	config := newTableConfiguration(r, t, form)
 	if !config.isValid() {
 		return tableRenderContext{}, ErrorTableInvalidRequest
 	}
+	if validator, ok := t.(tableConfigurationValidator); ok && !validator.isValidConfiguration(config) {
+		return tableRenderContext{}, ErrorTableInvalidRequest
+	}
 
 	// get column information
 	columns := t.ColumnInfo(config.Columns)
@@ -376,13 +469,24 @@ func newTableRenderContext(t TableSpec, form url.Values) (tableRenderContext, er
 	}
 
 	// load data
+	loadTimer := startComponentTimer("table", t.Name(), "load_data")
 	data, err := t.LoadData(config)
+	loadTimer.stop()
 	if err != nil {
+		componentEvent("table", t.Name(), "load_error")
 		return tableRenderContext{}, err
 	}
 
-	count, err := dbEntryCount(t.ModelName())
+	var count int64
+	countTimer := startComponentTimer("table", t.Name(), "count")
+	if counter, ok := t.(TableCount); ok {
+		count, err = counter.Count(config)
+	} else {
+		count, err = dbEntryCount(r.Context(), t.ModelName())
+	}
+	countTimer.stop()
 	if err != nil {
+		componentEvent("table", t.Name(), "load_error")
 		return tableRenderContext{}, err
 	}
 
@@ -461,6 +565,17 @@ func newTableRenderContext(t TableSpec, form url.Values) (tableRenderContext, er
 		context.Config.Rows,
 		strings.Join(context.Config.Columns, ","),
 	)
+	// .. keep filter settings
+	filterKeys := make([]string, 0, len(context.Config.Filter))
+	for key := range context.Config.Filter {
+		filterKeys = append(filterKeys, key)
+	}
+	sort.Strings(filterKeys)
+	for _, key := range filterKeys {
+		context.TableBaseURL += fmt.Sprintf(
+			"&f_%s=%s", url.QueryEscape(key), url.QueryEscape(context.Config.Filter[key]),
+		)
+	}
 	// .. calculate overall page count
 	if count != -1 {
 		context.PageCount = int(math.Ceil(float64(count) / float64(context.Config.Rows)))
@@ -470,7 +585,7 @@ func newTableRenderContext(t TableSpec, form url.Values) (tableRenderContext, er
 }
 
 func renderTable(w http.ResponseWriter, r *http.Request, t TableSpec, form url.Values) {
-	context, err := newTableRenderContext(t, form)
+	context, err := newTableRenderContext(r, t, form)
 	if err != nil {
 		handleTableError(w, r, "could not create table render context", err)
 		return
@@ -478,6 +593,7 @@ func renderTable(w http.ResponseWriter, r *http.Request, t TableSpec, form url.V
 
 	err = renderInternalTemplate(w, r, "table", context)
 	if err != nil {
+		componentEvent("table", t.Name(), "render_error")
 		Log.ErrorContextR(
 			r, "could not render table",
 			LogContext{"name": t.Name(), "error": err},
@@ -492,6 +608,9 @@ func handleTableError(w http.ResponseWriter, r *http.Request, message string, er
 	case ErrorTableInvalidRequest:
 		RespondBadRequest(w)
 		return
+	case ErrorTableForbidden:
+		RespondForbidden(w)
+		return
 	}
 
 	// all other cases: log error and respond
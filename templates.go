@@ -56,13 +56,7 @@ func hxResolve(r *http.Request, element, method, url, trigger, swap, attributes
 }
 
 func getTemplateFuncMap(r *http.Request) template.FuncMap {
-	var (
-		lang     = r.Context().Value(ctxClientLanguage).(string)
-		user, ok = r.Context().Value(ctxAppUser).(AppUser)
-	)
-	if ok && user.Language != "" {
-		lang = user.Language
-	}
+	lang := contextLanguage(r.Context())
 	Log.TraceContextR(r, "localize request", LogContext{"lang": lang})
 
 	trFunction := func(string, ...interface{}) string {
@@ -156,6 +150,44 @@ func preprocessTemplate(name string, template []byte) string {
 	return fmt.Sprintf(`{{define "%s"}}%s{{end}}`, name, string(template))
 }
 
+// parseTemplate parses the given template content. Records parse duration and errors as
+// template metrics using the specified template name.
+func parseTemplate(r *http.Request, name, templateName string, templateFile []byte) (*template.Template, error) {
+	defer startComponentTimer("template", templateName, "parse").stop()
+
+	_, span := startSpan(r, "template.parse "+templateName)
+	defer span.End()
+
+	tmpl, err := template.New("").Funcs(getTemplateFuncMap(r)).Parse(
+		preprocessTemplate(name, templateFile),
+	)
+	if err != nil {
+		componentEvent("template", templateName, "parse_error")
+		return nil, err
+	}
+
+	return tmpl, nil
+}
+
+// executeTemplate executes the given template. Records execution duration and errors as
+// template metrics using the specified template name.
+func executeTemplate(
+	w io.Writer,
+	tmpl *template.Template,
+	name string,
+	data interface{},
+	templateName string,
+) error {
+	defer startComponentTimer("template", templateName, "execute").stop()
+
+	err := tmpl.ExecuteTemplate(w, name, data)
+	if err != nil {
+		componentEvent("template", templateName, "execute_error")
+	}
+
+	return err
+}
+
 func renderTemplate(
 	w io.Writer,
 	r *http.Request,
@@ -163,18 +195,28 @@ func renderTemplate(
 	data interface{},
 	templateName string,
 ) error {
+	r, span := startSpan(r, "template "+templateName)
+	defer span.End()
+
 	templateFile, err := ReadFile(filepath.Join(Config.Assets.Templates, templateName))
 	if err != nil {
+		componentEvent("template", unknownComponentName, "load_error")
 		return err
 	}
-	templateString := preprocessTemplate(name, templateFile)
 
-	tmpl, err := template.New("").Funcs(getTemplateFuncMap(r)).Parse(templateString)
+	tmpl, err := parseTemplate(r, name, templateName, templateFile)
 	if err != nil {
 		return err
 	}
 
-	return tmpl.ExecuteTemplate(w, name, data)
+	return executeTemplate(w, tmpl, name, data, templateName)
+}
+
+// internalTemplateIncludes lists the internal templates used by other internal templates.
+var internalTemplateIncludes = map[string][]string{
+	"form":           {"form_field", "form_group_row"},
+	"form_field":     {"form_group_row"},
+	"form_group_row": {"form_field"},
 }
 
 func renderInternalTemplate(
@@ -183,18 +225,33 @@ func renderInternalTemplate(
 	name string,
 	data interface{},
 ) error {
+	r, span := startSpan(r, "template "+name)
+	defer span.End()
+
 	templateFile, err := templateFS.ReadFile("templates/" + name)
 	if err != nil {
 		return err
 	}
-	templateString := preprocessTemplate(name, templateFile)
 
-	tmpl, err := template.New("").Funcs(getTemplateFuncMap(r)).Parse(templateString)
+	tmpl, err := parseTemplate(r, name, name, templateFile)
 	if err != nil {
 		return err
 	}
 
-	return tmpl.ExecuteTemplate(w, name, data)
+	for _, include := range internalTemplateIncludes[name] {
+		includeFile, err := templateFS.ReadFile("templates/" + include)
+		if err != nil {
+			return err
+		}
+
+		_, err = tmpl.Parse(preprocessTemplate(include, includeFile))
+		if err != nil {
+			componentEvent("template", name, "parse_error")
+			return err
+		}
+	}
+
+	return executeTemplate(w, tmpl, name, data, name)
 }
 
 func loadTemplate(
@@ -209,6 +266,7 @@ func loadTemplate(
 	info, err := os.Stat(templatePath)
 	if err != nil {
 		if os.IsNotExist(err) {
+			componentEvent("template", unknownComponentName, "not_found")
 			Log.WarnContextR(
 				r, "file not found",
 				LogContext{"file": templatePath},
@@ -234,6 +292,7 @@ func loadTemplate(
 	// load template
 	templateFile, err := ReadFile(templatePath)
 	if err != nil {
+		componentEvent("template", templateName, "load_error")
 		Log.ErrorContextR(
 			r, "could not read template file",
 			LogContext{
@@ -243,10 +302,9 @@ func loadTemplate(
 		)
 		return nil, http.StatusInternalServerError
 	}
-	templateString := preprocessTemplate(name, templateFile)
 
 	// parse template
-	tmpl, err := template.New("").Funcs(getTemplateFuncMap(r)).Parse(templateString)
+	tmpl, err := parseTemplate(r, name, templateName, templateFile)
 	if err != nil {
 		Log.ErrorContextR(
 			r, "could not parse template file",
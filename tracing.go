@@ -0,0 +1,274 @@
+package uos
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+)
+
+const tracerName = "github.com/mschoebel/urban-octo-succotash"
+
+var traceProvider *sdktrace.TracerProvider
+
+func setupTracing() {
+	if !Config.Tracing.Enabled {
+		Log.Debug("tracing disabled")
+		return
+	}
+
+	serviceName := Config.Tracing.ServiceName
+	if serviceName == "" {
+		serviceName = filepath.Base(os.Args[0])
+	}
+
+	sampleRatio := Config.Tracing.SampleRatio
+	if sampleRatio <= 0 || sampleRatio > 1 {
+		sampleRatio = 1
+	}
+
+	options := []sdktrace.TracerProviderOption{
+		sdktrace.WithResource(
+			resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceName(serviceName)),
+		),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(sampleRatio))),
+	}
+
+	switch Config.Tracing.Exporter {
+	case "", "stdout":
+		exporter, err := stdouttrace.New()
+		if err != nil {
+			Log.PanicError("could not initialize trace exporter", err)
+		}
+		options = append(options, sdktrace.WithBatcher(exporter))
+	case "none":
+		// exporters are registered by the application (RegisterTraceExporter)
+	default:
+		Log.PanicContext("unknown trace exporter", LogContext{"exporter": Config.Tracing.Exporter})
+		panic("unknown trace exporter")
+	}
+
+	Log.InfoContext(
+		"initialize tracing",
+		LogContext{"service": serviceName, "exporter": Config.Tracing.Exporter, "ratio": sampleRatio},
+	)
+
+	traceProvider = sdktrace.NewTracerProvider(options...)
+	otel.SetTracerProvider(traceProvider)
+	otel.SetTextMapPropagator(
+		propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}),
+	)
+}
+
+func cleanupTracing() {
+	if traceProvider == nil {
+		return
+	}
+
+	err := traceProvider.Shutdown(context.Background())
+	if err != nil {
+		Log.ErrorObj("could not shutdown trace provider", err)
+	}
+}
+
+// RegisterTraceExporter adds an additional span exporter, e.g. an OTLP exporter. Spans are
+// exported in batches - use FlushTraces to export pending spans immediately (e.g. in tests).
+// Must be called after ComponentSetup. Does nothing if tracing is disabled.
+func RegisterTraceExporter(exporter sdktrace.SpanExporter) {
+	if traceProvider == nil {
+		Log.Warn("tracing disabled - ignore trace exporter registration")
+		return
+	}
+
+	traceProvider.RegisterSpanProcessor(sdktrace.NewBatchSpanProcessor(exporter))
+}
+
+// FlushTraces exports all pending spans of the registered exporters. Does nothing if tracing is
+// disabled.
+func FlushTraces(ctx context.Context) error {
+	if traceProvider == nil {
+		return nil
+	}
+	return traceProvider.ForceFlush(ctx)
+}
+
+// Tracer returns the framework tracer. Can be used to create application specific spans.
+func Tracer() trace.Tracer {
+	return otel.Tracer(tracerName)
+}
+
+// startSpan starts a child span of the request span and returns the request with the updated context.
+func startSpan(r *http.Request, name string, attributes ...attribute.KeyValue) (*http.Request, trace.Span) {
+	ctx, span := Tracer().Start(r.Context(), name, trace.WithAttributes(attributes...))
+	return r.WithContext(ctx), span
+}
+
+// startRequestSpan starts the root span of a request. Continues a trace based on the W3C trace
+// context headers of the request.
+func startRequestSpan(r *http.Request) (*http.Request, trace.Span) {
+	ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+	ctx, span := Tracer().Start(
+		ctx,
+		r.Method+" "+r.URL.Path,
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(
+			semconv.HTTPMethod(r.Method),
+			semconv.HTTPTarget(r.URL.Path),
+		),
+	)
+	return r.WithContext(ctx), span
+}
+
+// endRequestSpanOnPanic ends the request span of a panicking request handler (status 500) and
+// continues panicking. Must be called using defer.
+func endRequestSpanOnPanic(span trace.Span) {
+	if p := recover(); p != nil {
+		span.RecordError(fmt.Errorf("panic: %v", p))
+		endRequestSpan(span, http.StatusInternalServerError)
+		panic(p)
+	}
+}
+
+func endRequestSpan(span trace.Span, statusCode int) {
+	span.SetAttributes(semconv.HTTPStatusCode(statusCode))
+	if statusCode >= 500 {
+		span.SetStatus(codes.Error, http.StatusText(statusCode))
+	}
+	span.End()
+}
+
+// TraceTransport returns a HTTP transport creating client spans and propagating the trace
+// context (W3C headers) to the called service. Uses http.DefaultTransport if base is nil.
+// Usage: client := &http.Client{Transport: uos.TraceTransport(nil)}
+func TraceTransport(base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &traceTransport{base: base}
+}
+
+type traceTransport struct {
+	base http.RoundTripper
+}
+
+func (t *traceTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	ctx, span := Tracer().Start(
+		r.Context(),
+		r.Method+" "+r.URL.Host,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.HTTPMethod(r.Method),
+			semconv.HTTPURL(r.URL.String()),
+		),
+	)
+	defer span.End()
+
+	r = r.Clone(ctx)
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(r.Header))
+
+	response, err := t.base.RoundTrip(r)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+
+	span.SetAttributes(semconv.HTTPStatusCode(response.StatusCode))
+	if response.StatusCode >= 500 {
+		span.SetStatus(codes.Error, http.StatusText(response.StatusCode))
+	}
+
+	return response, nil
+}
+
+// registerDBTracing adds GORM callbacks creating a span for every database operation.
+// Spans are children of the request span if the query uses the request context (see DBContext).
+func registerDBTracing(db *gorm.DB) {
+	if traceProvider == nil {
+		return
+	}
+
+	callbacks := db.Callback()
+	errs := []error{
+		callbacks.Create().Before("gorm:create").Register("uos:trace_before_create", dbTraceBefore("create")),
+		callbacks.Create().After("gorm:create").Register("uos:trace_after_create", dbTraceAfter),
+		callbacks.Query().Before("gorm:query").Register("uos:trace_before_query", dbTraceBefore("query")),
+		callbacks.Query().After("gorm:query").Register("uos:trace_after_query", dbTraceAfter),
+		callbacks.Update().Before("gorm:update").Register("uos:trace_before_update", dbTraceBefore("update")),
+		callbacks.Update().After("gorm:update").Register("uos:trace_after_update", dbTraceAfter),
+		callbacks.Delete().Before("gorm:delete").Register("uos:trace_before_delete", dbTraceBefore("delete")),
+		callbacks.Delete().After("gorm:delete").Register("uos:trace_after_delete", dbTraceAfter),
+		callbacks.Row().Before("gorm:row").Register("uos:trace_before_row", dbTraceBefore("row")),
+		callbacks.Row().After("gorm:row").Register("uos:trace_after_row", dbTraceAfter),
+		callbacks.Raw().Before("gorm:raw").Register("uos:trace_before_raw", dbTraceBefore("raw")),
+		callbacks.Raw().After("gorm:raw").Register("uos:trace_after_raw", dbTraceAfter),
+	}
+	for _, err := range errs {
+		if err != nil {
+			Log.PanicError("could not register DB tracing callbacks", err)
+		}
+	}
+}
+
+func dbTraceBefore(operation string) func(*gorm.DB) {
+	return func(tx *gorm.DB) {
+		ctx := tx.Statement.Context
+		if ctx == nil {
+			ctx = context.Background()
+		}
+
+		ctx, span := Tracer().Start(
+			ctx,
+			"db."+operation,
+			trace.WithSpanKind(trace.SpanKindClient),
+			trace.WithAttributes(dbSystemAttribute()),
+		)
+		tx.Statement.Context = ctx
+		tx.InstanceSet("uos:span", span)
+	}
+}
+
+func dbSystemAttribute() attribute.KeyValue {
+	switch Config.Database.Driver {
+	case "postgres":
+		return semconv.DBSystemPostgreSQL
+	case "mysql":
+		return semconv.DBSystemMySQL
+	}
+
+	return semconv.DBSystemSqlite
+}
+
+func dbTraceAfter(tx *gorm.DB) {
+	value, ok := tx.InstanceGet("uos:span")
+	if !ok {
+		return
+	}
+	span, ok := value.(trace.Span)
+	if !ok {
+		return
+	}
+
+	span.SetAttributes(
+		semconv.DBStatement(tx.Statement.SQL.String()),
+		semconv.DBSQLTableKey.String(tx.Statement.Table),
+	)
+	if tx.Error != nil && tx.Error != gorm.ErrRecordNotFound {
+		span.RecordError(tx.Error)
+		span.SetStatus(codes.Error, tx.Error.Error())
+	}
+
+	span.End()
+}
@@ -73,6 +73,11 @@ func RespondBadRequest(w http.ResponseWriter) {
 	respondWithStatusText(w, http.StatusBadRequest)
 }
 
+// RespondForbidden sends "forbidden" error.
+func RespondForbidden(w http.ResponseWriter) {
+	respondWithStatusText(w, http.StatusForbidden)
+}
+
 // RespondNotImplemented sends "not implemented" error.
 func RespondNotImplemented(w http.ResponseWriter) {
 	respondWithStatusText(w, http.StatusNotImplemented)
@@ -102,6 +107,17 @@ func stringToInt(s string, defaultValue int) int {
 	return v
 }
 
+// isSimpleName checks whether the name only contains letters, digits, "-" and "_".
+func isSimpleName(name string) bool {
+	for _, c := range name {
+		isLetter := c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+		if !isLetter && (c < '0' || c > '9') && c != '-' && c != '_' {
+			return false
+		}
+	}
+	return name != ""
+}
+
 func contains(list []string, s string) bool {
 	for _, v := range list {
 		if v == s {
@@ -111,6 +127,15 @@ func contains(list []string, s string) bool {
 	return false
 }
 
+// joinIDs returns the given IDs as comma separated list.
+func joinIDs(ids []uint) string {
+	list := make([]string, len(ids))
+	for i, id := range ids {
+		list[i] = strconv.FormatUint(uint64(id), 10)
+	}
+	return strings.Join(list, ",")
+}
+
 func randomString(length int) string {
 	var (
 		charset    = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"